@@ -0,0 +1,30 @@
+package main
+
+// #cgo pkg-config: gtk+-3.0
+// #include <gtk/gtk.h>
+//
+// static void set_accel_label(GtkWidget *widget, guint key, GdkModifierType mods) {
+//     if (GTK_IS_ACCEL_LABEL(widget)) {
+//         gtk_accel_label_set_accel(GTK_ACCEL_LABEL(widget), key, mods);
+//     }
+// }
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// showAccel shows accel, such as "<Control>S", next to the label of mi. The
+// shortcut itself is an application accelerator which menu items built by
+// hand do not show, and gotk3 does not wrap gtk_accel_label_set_accel().
+func showAccel(mi *gtk.MenuItem, accel string) {
+	child, err := mi.GetChild()
+	if err != nil || child == nil {
+		return
+	}
+
+	key, mods := gtk.AcceleratorParse(accel)
+	C.set_accel_label((*C.GtkWidget)(unsafe.Pointer(child.ToWidget().Native())), C.guint(key), C.GdkModifierType(mods))
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// actionAccels are the keyboard shortcuts of the actions. The first one of
+// each is shown in the menu.
+var actionAccels = map[string][]string{
+	"app.new-window": {"<Control><Shift>N"},
+	"app.open":       {"<Control>O"},
+	"app.about":      {"F1"},
+	"app.zoom-in":    {"<Control>plus", "<Control>equal", "<Control>KP_Add"},
+	"app.zoom-out":   {"<Control>minus", "<Control>KP_Subtract"},
+	"app.zoom-reset": {"<Control>0", "<Control>KP_0"},
+
+	"win.new":         {"<Control>N"},
+	"win.save":        {"<Control>S"},
+	"win.close-tab":   {"<Control>W"},
+	"win.print":       {"<Control>P"},
+	"win.undo":        {"<Control>Z"},
+	"win.redo":        {"<Control>Y", "<Control><Shift>Z"},
+	"win.cut":         {"<Control>X"},
+	"win.copy":        {"<Control>C"},
+	"win.paste":       {"<Control>V"},
+	"win.find":        {"<Control>F"},
+	"win.find-next":   {"F3"},
+	"win.replace":     {"<Control>H"},
+	"win.goto":        {"<Control>G"},
+	"win.select-all":  {"<Control>A"},
+	"win.insert-time": {"F5"},
+}
+
+// addAction adds the action name to m, calling activate when it is
+// activated.
+func addAction(m glib.IActionMap, name string, activate func()) *glib.SimpleAction {
+	action := glib.SimpleActionNew(name, nil)
+	action.Connect("activate", activate)
+	m.AddAction(action)
+
+	return action
+}
+
+// addToggleAction adds the boolean action name to m, such as a check menu
+// item, calling change with the state asked for.
+func addToggleAction(m glib.IActionMap, name string, state bool, change func(bool)) *glib.SimpleAction {
+	action := glib.SimpleActionNewStateful(name, nil, glib.VariantFromBoolean(state))
+	action.Connect("change-state", func(_ *glib.SimpleAction, value bool) {
+		action.SetState(glib.VariantFromBoolean(value))
+		change(value)
+	})
+	m.AddAction(action)
+
+	return action
+}
+
+// addRadioAction adds the string action name to m, such as a group of radio
+// menu items, calling change with the state asked for.
+func addRadioAction(m glib.IActionMap, name string, state string, change func(string)) *glib.SimpleAction {
+	action := glib.SimpleActionNewStateful(name, glib.VARIANT_TYPE_STRING, glib.VariantFromString(state))
+	action.Connect("change-state", func(_ *glib.SimpleAction, value string) {
+		action.SetState(glib.VariantFromString(value))
+		change(value)
+	})
+	m.AddAction(action)
+
+	return action
+}
+
+// setupActions adds the actions which work without a window and the keyboard
+// shortcuts of all actions.
+func (ap *application) setupActions() {
+	ap.actions = make(map[string]*glib.SimpleAction)
+
+	addAction(ap.gtkApp.IActionMap, "new-window", func() {
+		ap.NewWindow()
+	})
+
+	addAction(ap.gtkApp.IActionMap, "open", func() {
+		ap.window().ShowOpenDialog()
+	})
+
+	addAction(ap.gtkApp.IActionMap, "about", func() {
+		displayAboutDialog(ap.window())
+	})
+
+	addAction(ap.gtkApp.IActionMap, "quit", func() {
+		ap.Quit()
+	})
+
+	addAction(ap.gtkApp.IActionMap, "zoom-in", func() {
+		ap.SetZoom(ap.zoom + zoomStep)
+	})
+
+	addAction(ap.gtkApp.IActionMap, "zoom-out", func() {
+		ap.SetZoom(ap.zoom - zoomStep)
+	})
+
+	addAction(ap.gtkApp.IActionMap, "zoom-reset", func() {
+		ap.SetZoom(100)
+	})
+
+	// Picking a scheme stops following the desktop.
+	ap.actions["scheme"] = addRadioAction(ap.gtkApp.IActionMap, "scheme", ap.config.Scheme.Name, func(name string) {
+		if name == ap.scheme.Name {
+			return
+		}
+
+		ap.config.Scheme.Name = name
+		ap.config.Scheme.FollowDesktop = false
+		ap.applyScheme()
+		ap.SaveConfig(map[string]interface{}{
+			"scheme.name":          name,
+			"scheme.followdesktop": false,
+		})
+	})
+
+	for name, accels := range actionAccels {
+		ap.gtkApp.SetAccelsForAction(name, accels)
+	}
+}
+
+// setupActions adds the actions of the window, they work on the document of
+// the selected tab.
+func (a *app) setupActions() {
+	a.actions = make(map[string]*glib.SimpleAction)
+
+	add := func(name string, activate func()) {
+		a.actions[name] = addAction(a.Win.IActionMap, name, activate)
+	}
+
+	add("new", func() {
+		a.NewDocument()
+	})
+
+	add("save", func() {
+		a.doc.Save()
+	})
+
+	add("save-as", func() {
+		a.doc.SaveAs()
+	})
+
+	add("close-tab", func() {
+		a.CloseDocument(a.doc)
+	})
+
+	add("page-setup", func() {
+		displayPageSetupDialog(a)
+	})
+
+	add("print-preview", func() {
+		a.Print(gtk.PRINT_OPERATION_ACTION_PREVIEW, "")
+	})
+
+	add("print", func() {
+		a.Print(gtk.PRINT_OPERATION_ACTION_PRINT_DIALOG, "")
+	})
+
+	add("export-pdf", func() {
+		a.ExportPDF()
+	})
+
+	add("undo", func() {
+		a.doc.textView.history.Undo()
+	})
+
+	add("redo", func() {
+		a.doc.textView.history.Redo()
+	})
+
+	add("cut", func() {
+		a.doc.textView.Cut()
+	})
+
+	add("copy", func() {
+		a.doc.textView.Copy()
+	})
+
+	add("paste", func() {
+		a.doc.textView.Paste()
+	})
+
+	add("delete", func() {
+		a.doc.textView.Backspace()
+	})
+
+	add("find", func() {
+		a.ShowFindDialog()
+	})
+
+	add("find-next", func() {
+		if a.search.query == "" {
+			a.ShowFindDialog()
+			return
+		}
+
+		a.FindNext(a.search)
+	})
+
+	add("replace", func() {
+		a.ShowReplaceDialog()
+	})
+
+	add("goto", func() {
+		response, line := displayGotoLine(a)
+
+		if response == gtk.RESPONSE_OK {
+			i, _ := strconv.ParseInt(line, 10, 64)
+			a.doc.textView.GoToLine(int(i - 1))
+		}
+	})
+
+	add("select-all", func() {
+		a.doc.textView.SelectAll()
+	})
+
+	add("insert-time", func() {
+		a.doc.textView.InsertTimestamp()
+	})
+
+	add("font", func() {
+		a.ShowFontDialog()
+	})
+
+	a.actions["line-numbers"] = addToggleAction(a.Win.IActionMap, "line-numbers", false, func(visible bool) {
+		a.application.SetLineNumbers(visible)
+	})
+
+	a.actions["word-wrap"] = addToggleAction(a.Win.IActionMap, "word-wrap", a.config.Font.Wrap, func(wrap bool) {
+		a.doc.SetWrap(wrap)
+
+		// New documents are wrapped the same.
+		if a.config.Font.Wrap != wrap {
+			a.config.Font.Wrap = wrap
+			a.application.SaveConfig(map[string]interface{}{"font.wrap": wrap})
+		}
+	})
+
+	a.actions["line-ending"] = addRadioAction(a.Win.IActionMap, "line-ending", defaultLineEnding().name(), func(name string) {
+		ending, ok := lineEndingByName(name)
+		if !ok || ending == a.doc.lineEnding {
+			return
+		}
+
+		a.doc.lineEnding = ending
+		a.doc.textView.history.ForgetSavePoint()
+		a.doc.hasChanges = true
+		a.doc.UpdateTitle()
+		a.updateStatusBar()
+	})
+
+	a.actions["language"] = addRadioAction(a.Win.IActionMap, "language", plainTextID, func(id string) {
+		a.doc.languageChosen = true
+		a.doc.SetLanguage(languageByID(id))
+	})
+
+	a.actions["status-bar"] = addToggleAction(a.Win.IActionMap, "status-bar", false, func(visible bool) {
+		if visible {
+			a.statusBar.Show()
+			a.updateStatusBar()
+		} else {
+			a.statusBar.Hide()
+		}
+
+		if a.config.StatusBar.Enable != visible {
+			a.config.StatusBar.Enable = visible
+			a.application.SaveConfig(map[string]interface{}{"statusbar.enable": visible})
+		}
+	})
+
+	a.actions["undo"].SetEnabled(false)
+	a.actions["redo"].SetEnabled(false)
+	a.actions["cut"].SetEnabled(false)
+	a.actions["copy"].SetEnabled(false)
+	a.actions["delete"].SetEnabled(false)
+}
+
+// ShowOpenDialog asks for a file and an encoding and opens the file.
+func (a *app) ShowOpenDialog() {
+	fc, _ := gtk.FileChooserNativeDialogNew("Open File", a.Win, gtk.FILE_CHOOSER_ACTION_OPEN, "Open", "Cancel")
+	addEncodingChoice(&fc.FileChooser, true, encodingAuto)
+	response := fc.Run()
+	filename := fc.GetFilename()
+	enc := chosenEncoding(&fc.FileChooser)
+	fc.Destroy()
+
+	if response == int(gtk.RESPONSE_ACCEPT) {
+		a.OpenFile(filename, enc)
+	}
+}
+
+// ShowFontDialog asks for the font of the text, which is kept in the config.
+func (a *app) ShowFontDialog() {
+	fd, err := gtk.FontChooserDialogNew(appName, a.Win)
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error creating font chooser dialog:\n\n%s", err)
+		fmt.Printf("failed creating font chooser dialog: %s\n", err)
+		return
+	}
+
+	defer fd.Destroy()
+
+	fd.SetFont(fmt.Sprintf("%s %d", a.config.Font.Family, a.config.Font.Size))
+
+	fd.ShowAll()
+	response := fd.Run()
+
+	if response != gtk.RESPONSE_OK {
+		return
+	}
+
+	fontText := fd.GetFont()
+
+	// Pango sizes can have a fraction, such as "DejaVu Sans Mono 10.5", the
+	// config keeps whole points.
+	fontTokens := strings.Split(fontText, " ")
+	points, err := strconv.ParseFloat(fontTokens[len(fontTokens)-1], 64)
+
+	if err == nil && points <= 0 {
+		err = fmt.Errorf("invalid font size in %q", fontText)
+	}
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error extracting font size:\n\n%s", err)
+		fmt.Printf("failed selecting font: %s\n", err)
+		return
+	}
+
+	fontSize := int64(math.Round(points))
+	if fontSize < 1 {
+		fontSize = 1
+	}
+
+	fontFamily := strings.Join(fontTokens[:len(fontTokens)-1], " ")
+	fontFamily = strings.Trim(fontFamily, ",")
+
+	size := a.application.zoomed(fontSize)
+	err = a.doc.textView.SetFont(fontFamily, size)
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error choosing font:\n\n%s", err)
+		return
+	}
+
+	// The text of every tab takes the font, their gutters follow it.
+	for _, d := range a.application.documents() {
+		d.textView.gutter.SetFont(fontFamily, size)
+	}
+
+	// Only a font which was applied is kept.
+	a.config.Font.Family = fontFamily
+	a.config.Font.Size = fontSize
+	a.application.SaveConfig(map[string]interface{}{
+		"font.family": fontFamily,
+		"font.size":   fontSize,
+	})
+}
@@ -0,0 +1,394 @@
+package main
+
+// #cgo pkg-config: gio-2.0
+// #include <gio/gio.h>
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// applicationID names the editor on D-Bus and in its .desktop file.
+const applicationID = "com.github.ajm113.GoNotepad"
+
+// Zoom levels in percent, as View > Zoom steps through them.
+const (
+	zoomMin  = 10
+	zoomMax  = 500
+	zoomStep = 10
+)
+
+type (
+	// application is the editor process. It owns the windows and what they
+	// share: the config, the file watcher, the recovery journal and the socket
+	// other editors hand their files to.
+	application struct {
+		gtkApp  *gtk.Application
+		windows []*app
+		opts    *cliOptions
+		actions map[string]*glib.SimpleAction
+
+		config       *ConfigSchema
+		fileWatcher  *fileWatcher
+		fileMetadata *fileMetadataStore
+		recovery     *recovery
+		instance     *instanceServer
+
+		// configFilename is where preferences changed in the menus are saved.
+		configFilename string
+
+		// scheme is the color scheme in use, one of schemes.
+		schemes         []*colorScheme
+		scheme          *colorScheme
+		schemeProvider  *gtk.CssProvider
+		desktopSettings *glib.Settings
+
+		// zoom scales the font of the text, in percent.
+		zoom int
+	}
+)
+
+// newApplication creates the editor process. Unless it is unique, other
+// editors started later do not hand it their files.
+func newApplication(opts *cliOptions, unique bool) *application {
+	flags := glib.APPLICATION_HANDLES_OPEN
+
+	// A --wait editor exits with its documents, so it must not be handed the
+	// files of editors started after it.
+	if opts.wait || !unique {
+		flags |= glib.APPLICATION_NON_UNIQUE
+	}
+
+	gtkApp, err := gtk.ApplicationNew(applicationID, flags)
+	if err != nil {
+		log.Fatal("failed creating application:", err)
+	}
+
+	ap := &application{
+		gtkApp: gtkApp,
+		opts:   opts,
+		zoom:   100,
+	}
+
+	gtkApp.Connect("startup", ap.startup)
+	gtkApp.Connect("activate", ap.activate)
+	gtkApp.Connect("open", ap.open)
+	gtkApp.Connect("shutdown", ap.shutdown)
+
+	return ap
+}
+
+// Run runs the main loop until the last window is closed and returns the exit
+// status.
+func (ap *application) Run(args []string) int {
+	// An editor which owns the application ID without answering on the
+	// instance socket can only be reached through GApplication, which hands
+	// it the files given to Run with the open signal instead of activate.
+	if ap.register() && ap.gtkApp.GetIsRemote() {
+		for _, f := range ap.opts.files {
+			args = append(args, f.Filename)
+		}
+	}
+
+	return ap.gtkApp.Run(args)
+}
+
+// register registers the application on the session bus, after which it is
+// either the primary instance or a remote for it.
+func (ap *application) register() bool {
+	var gerr *C.GError
+
+	gapp := (*C.GApplication)(unsafe.Pointer(ap.gtkApp.Application.Object.Native()))
+	ok := C.g_application_register(gapp, nil, &gerr) != 0
+
+	if gerr != nil {
+		fmt.Printf("failed registering application: %s\n", C.GoString((*C.char)(gerr.message)))
+		C.g_error_free(gerr)
+	}
+
+	return ok
+}
+
+func (ap *application) startup() {
+	ap.LoadConfig(ap.opts.config)
+	ap.fileWatcher = newFileWatcher(ap)
+	ap.fileMetadata = newFileMetadataStore()
+	ap.recovery = newRecovery(ap)
+	ap.schemes = loadColorSchemes(ap.config)
+	ap.setupActions()
+	ap.watchDesktopScheme()
+	ap.applyScheme()
+
+	// Only a single-instance editor can be driven by other processes.
+	if ap.opts.wait || !ap.config.Instance.Single {
+		ap.instance = &instanceServer{app: ap}
+	} else {
+		ap.instance = newInstanceServer(ap)
+	}
+
+	ap.recovery.Start()
+}
+
+// activate opens the first window with what was asked for on the command line.
+// Activating the editor again, such as from the desktop, brings it forward.
+func (ap *application) activate() {
+	if len(ap.windows) > 0 {
+		ap.activeWindow().Win.Present()
+		return
+	}
+
+	a := ap.window()
+	opts := ap.opts
+
+	if !opts.opensDocuments() && !opts.wait {
+		a.RestoreSession()
+	}
+
+	docs := a.Init(opts)
+
+	if opts.wait {
+		if len(docs) == 0 {
+			docs = append(docs, a.doc)
+		}
+
+		waitForClose(docs, a.Win.Close)
+	}
+}
+
+// open opens the files the desktop asked for, such as with "Open With".
+func (ap *application) open(_ *gtk.Application, files unsafe.Pointer, n int, _ string) {
+	a := ap.window()
+
+	for _, gfile := range unsafe.Slice((**C.GFile)(files), n) {
+		path := C.g_file_get_path(gfile)
+		if path == nil {
+			continue
+		}
+
+		a.OpenFile(C.GoString(path), nil)
+		C.g_free(C.gpointer(unsafe.Pointer(path)))
+	}
+
+	a.Win.Present()
+}
+
+func (ap *application) shutdown() {
+	ap.instance.Close()
+	ap.recovery.Clear()
+}
+
+// LoadConfig loads filename, or the first config file found in
+// ConfigFilePaths when it is empty.
+func (ap *application) LoadConfig(filename string) {
+	c, err := readConfig(filename)
+
+	if err != nil {
+		ap.UnexpectedErrorMessageBox("Unexpected error parsing config file: %s\n\nUsing defaults", err)
+		defaults := DefaultConfig
+		c = &defaults
+	}
+
+	ap.config = c
+	ap.configFilename = configFilename(filename)
+}
+
+// SaveConfig writes the values of the keys, such as "font.wrap", to the config
+// file so preferences changed in the menus last.
+func (ap *application) SaveConfig(values map[string]interface{}) {
+	if err := writeConfig(ap.configFilename, values); err != nil {
+		fmt.Printf("failed saving config %s: %s\n", ap.configFilename, err)
+	}
+}
+
+// UnexpectedErrorMessageBox shows the error over the active window, when there
+// is one yet.
+func (ap *application) UnexpectedErrorMessageBox(format string, args ...interface{}) {
+	if a := ap.activeWindow(); a != nil {
+		a.UnexpectedErrorMessageBox(format, args...)
+		return
+	}
+
+	d := gtk.MessageDialogNew(nil, 0, gtk.MESSAGE_ERROR, gtk.BUTTONS_OK, "")
+	d.FormatSecondaryText(format, args...)
+	d.SetTitle(appName)
+	d.Run()
+	d.Destroy()
+}
+
+// NewWindow opens another window with an Untitled document.
+func (ap *application) NewWindow() *app {
+	win, err := gtk.ApplicationWindowNew(ap.gtkApp)
+	if err != nil {
+		log.Fatal("failed creating window:", err)
+	}
+
+	a := &app{
+		application:  ap,
+		Win:          win,
+		config:       ap.config,
+		fileWatcher:  ap.fileWatcher,
+		fileMetadata: ap.fileMetadata,
+	}
+
+	ap.windows = append(ap.windows, a)
+
+	win.Connect("delete-event", func() bool {
+		return !a.confirmCloseWindow()
+	})
+
+	win.Connect("destroy", func() {
+		a.closed()
+	})
+
+	a.setupActions()
+	a.SetupWindow()
+
+	return a
+}
+
+// window returns the active window. When there is none the first one is
+// opened and offered the documents of editors which crashed.
+func (ap *application) window() *app {
+	if a := ap.activeWindow(); a != nil {
+		return a
+	}
+
+	a := ap.NewWindow()
+	ap.recovery.Recover(a)
+
+	return a
+}
+
+// activeWindow returns the window last focused, or nil when there is none.
+func (ap *application) activeWindow() *app {
+	if win := ap.gtkApp.GetActiveWindow(); win != nil {
+		for _, a := range ap.windows {
+			if a.Win.Window.Native() == win.Native() {
+				return a
+			}
+		}
+	}
+
+	if len(ap.windows) > 0 {
+		return ap.windows[len(ap.windows)-1]
+	}
+
+	return nil
+}
+
+// documents returns the documents of every window.
+func (ap *application) documents() []*document {
+	var docs []*document
+
+	for _, a := range ap.windows {
+		docs = append(docs, a.documents...)
+	}
+
+	return docs
+}
+
+// Quit closes every window, stopping at the first one which has unsaved
+// changes the user wants to keep.
+func (ap *application) Quit() {
+	for _, a := range append([]*app(nil), ap.windows...) {
+		if !a.confirmCloseWindow() {
+			return
+		}
+
+		a.Win.Destroy()
+	}
+}
+
+// confirmCloseWindow offers to save the documents of the window and reports
+// whether it may close. The last window to close remembers the session.
+func (a *app) confirmCloseWindow() bool {
+	if !a.CloseAll() {
+		return false
+	}
+
+	if len(a.application.windows) == 1 {
+		a.SaveSession()
+	}
+
+	a.SaveFileMetadata()
+
+	return true
+}
+
+// closed forgets the window once it is destroyed.
+func (a *app) closed() {
+	for _, d := range a.documents {
+		d.Watch("")
+
+		// Editors started with --wait are done once the window closes. The
+		// window of --wait itself closes when its documents are closed.
+		if !a.application.opts.wait {
+			d.closed()
+		}
+	}
+
+	ap := a.application
+
+	for i, w := range ap.windows {
+		if w == a {
+			ap.windows = append(ap.windows[:i], ap.windows[i+1:]...)
+			break
+		}
+	}
+}
+
+// zoomed returns the font size in points at the zoom level.
+func (ap *application) zoomed(size int64) float64 {
+	return float64(size) * float64(ap.zoom) / 100
+}
+
+// SetZoom scales the font of every window to zoom percent, between zoomMin
+// and zoomMax. The font size in the config is left as it is.
+func (ap *application) SetZoom(zoom int) {
+	if zoom < zoomMin {
+		zoom = zoomMin
+	} else if zoom > zoomMax {
+		zoom = zoomMax
+	}
+
+	ap.zoom = zoom
+	size := ap.zoomed(ap.config.Font.Size)
+
+	// The font is shared by the text of every tab, their gutters follow it.
+	for _, a := range ap.windows {
+		for _, d := range a.documents {
+			d.textView.gutter.SetFont(ap.config.Font.Family, size)
+		}
+
+		a.updateStatusBar()
+	}
+
+	if a := ap.activeWindow(); a != nil {
+		if err := a.doc.textView.SetFont(ap.config.Font.Family, size); err != nil {
+			a.UnexpectedErrorMessageBox("Unexpected error zooming:\n\n%s", err)
+		}
+	}
+}
+
+// SetLineNumbers shows or hides the line numbers of every tab of every
+// window, keeping the View menu of each window in step.
+func (ap *application) SetLineNumbers(visible bool) {
+	for _, a := range ap.windows {
+		a.lineNumbers = visible
+		a.actions["line-numbers"].SetState(glib.VariantFromBoolean(visible))
+	}
+
+	for _, d := range ap.documents() {
+		d.textView.gutter.SetVisible(visible)
+	}
+
+	if ap.config.LineNumbers.Enable != visible {
+		ap.config.LineNumbers.Enable = visible
+		ap.SaveConfig(map[string]interface{}{"linenumbers.enable": visible})
+	}
+}
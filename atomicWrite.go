@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces filename with data without ever leaving a half
+// written file behind. The data goes to a temporary file in the same
+// directory which is synced and then renamed over the original, keeping its
+// permissions, ownership and any symlink pointing at it.
+func writeFileAtomic(filename string, data []byte) (err error) {
+	// Write through symlinks instead of replacing the link itself.
+	filename, err = resolveSymlinks(filename)
+	if err != nil {
+		return err
+	}
+
+	mode := defaultFileMode()
+	info, statErr := os.Stat(filename)
+
+	if statErr == nil {
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	} else if !errors.Is(statErr, fs.ErrNotExist) {
+		return statErr
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if errors.Is(err, fs.ErrPermission) && info != nil {
+		// A writable file in a read-only directory can only be overwritten.
+		return writeFileInPlace(filename, data)
+	} else if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if info != nil {
+		// Only root can hand files to other users, so this is best effort.
+		// It comes first as changing the owner clears the setuid bits.
+		copyFileOwner(tmp.Name(), info)
+	}
+
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// maxSymlinks is how many symlinks resolveSymlinks follows before giving up on
+// a loop, as Linux does.
+const maxSymlinks = 40
+
+// resolveSymlinks follows filename through any symlinks to the file they end
+// at. The file a dangling symlink points to is returned as is, so saving
+// creates it and keeps the link, instead of replacing the link by a file.
+func resolveSymlinks(filename string) (string, error) {
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := os.Lstat(filename)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return filename, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return filename, nil
+		}
+
+		target, err := os.Readlink(filename)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filename), target)
+		}
+
+		filename = target
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic links: %s", filename)
+}
+
+// writeFileInPlace overwrites the existing file filename with data, for when
+// no temporary file can be made next to it.
+func writeFileInPlace(filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		exists bool
+	}{
+		{"link to file", "file.txt", true},
+		{"dangling link", "missing.txt", false},
+		{"link into directory", filepath.Join("sub", "file.txt"), true},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		target := filepath.Join(dir, tt.target)
+		link := filepath.Join(dir, "link.txt")
+
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.exists {
+			if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := os.Symlink(tt.target, link); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writeFileAtomic(link, []byte("new")); err != nil {
+			t.Errorf("%s: writeFileAtomic() error: %s", tt.name, err)
+			continue
+		}
+
+		if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: the symlink was replaced", tt.name)
+		}
+
+		if data, err := os.ReadFile(target); err != nil || string(data) != "new" {
+			t.Errorf("%s: target holds %q, %v, want \"new\"", tt.name, data, err)
+		}
+	}
+}
+
+func TestWriteFileAtomicSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "loop.txt")
+
+	if err := os.Symlink("loop.txt", link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(link, []byte("new")); err == nil {
+		t.Errorf("writeFileAtomic() through a symlink loop did not fail")
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.sh")
+
+	if err := os.WriteFile(filename, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := os.FileMode(0750) | os.ModeSetuid
+	if err := os.Chmod(filename, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(filename, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic() error: %s", err)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := info.Mode(); got != want {
+		t.Errorf("mode = %s, want %s", got, want)
+	}
+}
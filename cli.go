@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// fileArg is a file named on the command line, Line and Column are one
+	// based and zero when not given.
+	fileArg struct {
+		Filename string
+		Line     int
+		Column   int
+	}
+
+	cliOptions struct {
+		files    []fileArg
+		args     []string
+		stdin    bool
+		input    []byte
+		create   bool
+		readOnly bool
+		wait     bool
+		encoding *textEncoding
+		config   string
+		remote   string
+	}
+)
+
+var (
+	lineArgRegexp     = regexp.MustCompile(`^\+(\d+)$`)
+	fileLineArgRegexp = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
+)
+
+const usage = `Usage: %[1]s [options] [+line] [file[:line[:column]]]... [-]
+       %[1]s --remote command [argument]...
+
+Opens every file in its own tab. "+line" jumps to a line of the file after
+it and "-" reads a document from stdin.
+
+With --remote the command is carried out by the running editor:
+
+  open file...      opens the files like they were given on the command line
+  goto line[:col]   moves the cursor of the current document
+  insert text|-     inserts text, or stdin, at the cursor
+  save [file]...    saves the current document or the named open files
+  quit              closes the editor
+
+Options:
+`
+
+// parseArgs parses the command line. Options and files may be mixed, "--"
+// ends the options. Errors are printed along with the usage.
+func parseArgs(args []string) (*cliOptions, error) {
+	opts := &cliOptions{}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, fs.Name())
+		fs.PrintDefaults()
+	}
+
+	encoding := fs.String("encoding", "", "decode the files with `name` instead of detecting it, such as UTF-8 or Windows-1252")
+	fs.BoolVar(&opts.create, "new", false, "open files which do not exist yet, they are created on save")
+	fs.BoolVar(&opts.readOnly, "readonly", false, "open the files read only")
+	fs.BoolVar(&opts.wait, "wait", false, "do not return until the opened files are closed, for use as $EDITOR")
+	fs.StringVar(&opts.config, "config", "", "load the config from `file` instead of searching for .notepad.yml")
+	fs.StringVar(&opts.remote, "remote", "", "send `command` to the running editor, see above")
+
+	rest, positional := args[1:], []string(nil)
+
+	for i, arg := range rest {
+		if arg == "--" {
+			rest, positional = rest[:i], rest[i+1:]
+			break
+		}
+	}
+
+	// lineArg is a +N waiting for the file it goes with.
+	line, lineArg := 0, ""
+	addFile := func(arg string, literal bool) {
+		f := fileArg{Filename: arg, Line: line}
+		line, lineArg = 0, ""
+
+		// A file name which exists wins over a :line:col suffix.
+		if m := fileLineArgRegexp.FindStringSubmatch(arg); m != nil && !literal && !fileExist(arg) {
+			f.Filename = m[1]
+			f.Line, _ = strconv.Atoi(m[2])
+			f.Column, _ = strconv.Atoi(m[3])
+		}
+
+		opts.files = append(opts.files, f)
+	}
+
+	for {
+		if err := fs.Parse(rest); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		arg := fs.Arg(0)
+		rest = fs.Args()[1:]
+		opts.args = append(opts.args, arg)
+
+		switch {
+		case arg == "-":
+			opts.stdin = true
+		case lineArgRegexp.MatchString(arg):
+			line, _ = strconv.Atoi(arg[1:])
+			lineArg = arg
+		default:
+			addFile(arg, false)
+		}
+	}
+
+	for _, arg := range positional {
+		opts.args = append(opts.args, arg)
+		addFile(arg, true)
+	}
+
+	if lineArg != "" {
+		err := fmt.Errorf("%s must be followed by a file to open", lineArg)
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+
+		return nil, err
+	}
+
+	if *encoding != "" {
+		if opts.encoding = encodingByName(*encoding); opts.encoding == nil {
+			var names []string
+			for _, e := range textEncodings {
+				names = append(names, e.Name)
+			}
+
+			err := fmt.Errorf("unknown encoding %q, use one of: %s", *encoding, strings.Join(names, ", "))
+			fmt.Fprintln(fs.Output(), err)
+
+			return nil, err
+		}
+	}
+
+	if opts.stdin {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			err = fmt.Errorf("failed reading stdin: %w", err)
+			fmt.Fprintln(fs.Output(), err)
+
+			return nil, err
+		}
+
+		opts.input = data
+	}
+
+	return opts, nil
+}
+
+// Init opens what was asked for on the command line and returns the opened
+// documents.
+func (a *app) Init(opts *cliOptions) []*document {
+	var docs []*document
+	var missing []string
+
+	for _, f := range opts.files {
+		var d *document
+
+		switch {
+		case fileExist(f.Filename):
+			d = a.OpenFile(f.Filename, opts.encoding)
+		case opts.create:
+			d = a.blankDocument()
+			d.NewFile(f.Filename, opts.encoding)
+		default:
+			missing = append(missing, f.Filename)
+			continue
+		}
+
+		if f.Line > 0 {
+			d.textView.GoToLineColumn(f.Line-1, f.Column-1)
+		}
+
+		if opts.readOnly {
+			d.SetReadOnly(true)
+		}
+
+		docs = append(docs, d)
+	}
+
+	if opts.stdin {
+		d := a.blankDocument()
+		d.LoadData(opts.input, opts.encoding)
+		d.SetReadOnly(opts.readOnly)
+
+		docs = append(docs, d)
+	}
+
+	if len(missing) > 0 {
+		a.UnexpectedErrorMessageBox("Cannot find %s\n\nUse --new to create files which do not exist.", strings.Join(missing, ", "))
+	}
+
+	return docs
+}
+
+// opensDocuments reports whether the command line names anything to open,
+// in which case the last session is not restored.
+func (opts *cliOptions) opensDocuments() bool {
+	return len(opts.files) > 0 || opts.stdin
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		files    []fileArg
+		encoding *textEncoding
+		readOnly bool
+		err      bool
+	}{
+		{
+			name:  "file",
+			args:  []string{"notes.txt"},
+			files: []fileArg{{Filename: "notes.txt"}},
+		},
+		{
+			name:  "file:line",
+			args:  []string{"notes.txt:12"},
+			files: []fileArg{{Filename: "notes.txt", Line: 12}},
+		},
+		{
+			name:  "file:line:column",
+			args:  []string{"notes.txt:12:5"},
+			files: []fileArg{{Filename: "notes.txt", Line: 12, Column: 5}},
+		},
+		{
+			name:  "+line file",
+			args:  []string{"+7", "notes.txt", "other.txt"},
+			files: []fileArg{{Filename: "notes.txt", Line: 7}, {Filename: "other.txt"}},
+		},
+		{
+			name:  "+line before double dash",
+			args:  []string{"+3", "--", "-notes.txt"},
+			files: []fileArg{{Filename: "-notes.txt", Line: 3}},
+		},
+		{
+			name: "trailing +line",
+			args: []string{"notes.txt", "+7"},
+			err:  true,
+		},
+		{
+			name: "trailing +0",
+			args: []string{"+0"},
+			err:  true,
+		},
+		{
+			name:     "options mixed with files",
+			args:     []string{"notes.txt", "--readonly", "other.txt"},
+			files:    []fileArg{{Filename: "notes.txt"}, {Filename: "other.txt"}},
+			readOnly: true,
+		},
+		{
+			name:     "encoding",
+			args:     []string{"--encoding", "windows-1252", "notes.txt"},
+			files:    []fileArg{{Filename: "notes.txt"}},
+			encoding: encodingCP1252,
+		},
+		{
+			name: "unknown encoding",
+			args: []string{"--encoding=klingon", "notes.txt"},
+			err:  true,
+		},
+		{
+			name:  "double dash",
+			args:  []string{"--", "--readonly", "notes.txt:12", "+3"},
+			files: []fileArg{{Filename: "--readonly"}, {Filename: "notes.txt:12"}, {Filename: "+3"}},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"--bogus", "notes.txt"},
+			err:  true,
+		},
+		{
+			name:  "windows path",
+			args:  []string{`C:\Users\me\notes.txt`},
+			files: []fileArg{{Filename: `C:\Users\me\notes.txt`}},
+		},
+		{
+			name:  "windows path with line",
+			args:  []string{`C:\Users\me\notes.txt:12:5`},
+			files: []fileArg{{Filename: `C:\Users\me\notes.txt`, Line: 12, Column: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseArgs(append([]string{"notepad"}, tt.args...))
+
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: parseArgs() did not fail", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: parseArgs() error: %s", tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(opts.files, tt.files) {
+			t.Errorf("%s: files = %+v, want %+v", tt.name, opts.files, tt.files)
+		}
+
+		if opts.encoding != tt.encoding {
+			t.Errorf("%s: encoding = %v, want %v", tt.name, opts.encoding, tt.encoding)
+		}
+
+		if opts.readOnly != tt.readOnly {
+			t.Errorf("%s: readOnly = %t, want %t", tt.name, opts.readOnly, tt.readOnly)
+		}
+	}
+}
+
+func TestParseArgsExistingFileWithColon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file names can not contain colons on Windows")
+	}
+
+	filename := filepath.Join(t.TempDir(), "notes.txt:12")
+	if err := os.WriteFile(filename, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseArgs([]string{"notepad", filename})
+	if err != nil {
+		t.Fatalf("parseArgs() error: %s", err)
+	}
+
+	want := []fileArg{{Filename: filename}}
+	if !reflect.DeepEqual(opts.files, want) {
+		t.Errorf("files = %+v, want %+v", opts.files, want)
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -24,12 +30,56 @@ var DefaultConfig = ConfigSchema{
 	StatusBar: ConfigStatusBar{
 		Enable: false,
 	},
+	LineNumbers: ConfigLineNumbers{
+		Enable: false,
+	},
+	Highlight: ConfigHighlight{
+		Enable: true,
+	},
+	Scheme: ConfigScheme{
+		Name:          "light",
+		FollowDesktop: false,
+		Dark:          "dark",
+		Directory:     "",
+	},
+	PageSetup: ConfigPageSetup{
+		Paper:        "",
+		Landscape:    false,
+		MarginTop:    25.4,
+		MarginBottom: 25.4,
+		MarginLeft:   19.05,
+		MarginRight:  19.05,
+		Header:       "&f",
+		Footer:       "Page &p",
+	},
+	Recovery: ConfigRecovery{
+		Enable:    true,
+		Interval:  30,
+		Directory: "",
+	},
+	Recent: ConfigRecent{
+		Size: 10,
+	},
+	Session: ConfigSession{
+		Enable: true,
+	},
+	Instance: ConfigInstance{
+		Single: false,
+	},
 }
 
 type (
 	ConfigSchema struct {
-		Font      ConfigFont
-		StatusBar ConfigStatusBar
+		Font        ConfigFont
+		StatusBar   ConfigStatusBar
+		LineNumbers ConfigLineNumbers
+		Highlight   ConfigHighlight
+		Scheme      ConfigScheme
+		PageSetup   ConfigPageSetup
+		Recovery    ConfigRecovery
+		Recent      ConfigRecent
+		Session     ConfigSession
+		Instance    ConfigInstance
 	}
 
 	ConfigFont struct {
@@ -41,6 +91,66 @@ type (
 	ConfigStatusBar struct {
 		Enable bool
 	}
+
+	// ConfigLineNumbers Enable shows the line number gutter.
+	ConfigLineNumbers struct {
+		Enable bool
+	}
+
+	// ConfigHighlight Enable colors the text of the languages in View >
+	// Language, turn it off for plain black text.
+	ConfigHighlight struct {
+		Enable bool
+	}
+
+	// ConfigScheme Name is the color scheme: light, dark, high-contrast or a
+	// user scheme from Directory, by default ~/go-notepad/schemes. With
+	// FollowDesktop the Dark scheme is used while the desktop prefers dark.
+	ConfigScheme struct {
+		Name          string
+		FollowDesktop bool
+		Dark          string
+		Directory     string
+	}
+
+	// ConfigPageSetup margins are in millimeters, Paper is a GTK paper name such
+	// as "iso_a4" or "na_letter" and empty for the locale default.
+	ConfigPageSetup struct {
+		Paper        string
+		Landscape    bool
+		MarginTop    float64
+		MarginBottom float64
+		MarginLeft   float64
+		MarginRight  float64
+		Header       string
+		Footer       string
+	}
+
+	// ConfigRecovery Interval is in seconds, an empty Directory uses the
+	// user cache directory.
+	ConfigRecovery struct {
+		Enable    bool
+		Interval  int64
+		Directory string
+	}
+
+	// ConfigRecent Size is the number of files in File > Recent Files, 0
+	// turns the list off.
+	ConfigRecent struct {
+		Size int
+	}
+
+	// ConfigSession Enable reopens the files and window geometry of the last
+	// run on launch.
+	ConfigSession struct {
+		Enable bool
+	}
+
+	// ConfigInstance Single opens files in the editor which is already
+	// running instead of a new editor process, which --remote needs.
+	ConfigInstance struct {
+		Single bool
+	}
 )
 
 func loadConfig(filePath string) (*ConfigSchema, error) {
@@ -50,8 +160,9 @@ func loadConfig(filePath string) (*ConfigSchema, error) {
 		return nil, err
 	}
 
-	// Unmarshal the YAML file into the Config struct
-	var config ConfigSchema
+	// Unmarshal the YAML file into the Config struct, keeping defaults for
+	// anything the file leaves out.
+	config := DefaultConfig
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, err
@@ -60,6 +171,16 @@ func loadConfig(filePath string) (*ConfigSchema, error) {
 	return &config, nil
 }
 
+// readConfig loads filename, or the first config file found in
+// ConfigFilePaths when it is empty.
+func readConfig(filename string) (*ConfigSchema, error) {
+	if filename != "" {
+		return loadConfig(filename)
+	}
+
+	return searchAndLoadConfig()
+}
+
 func searchAndLoadConfig() (*ConfigSchema, error) {
 	for _, c := range ConfigFilePaths {
 		if fileExist(c) {
@@ -67,5 +188,118 @@ func searchAndLoadConfig() (*ConfigSchema, error) {
 		}
 	}
 
-	return &DefaultConfig, nil
+	c := DefaultConfig
+	return &c, nil
+}
+
+// configFilename returns the config file LoadConfig reads for filename, or the
+// last of ConfigFilePaths, in the home directory, when there is none yet.
+func configFilename(filename string) string {
+	if filename != "" {
+		return filename
+	}
+
+	for _, c := range ConfigFilePaths {
+		if fileExist(c) {
+			return c
+		}
+	}
+
+	return ConfigFilePaths[len(ConfigFilePaths)-1]
+}
+
+// writeConfig sets the values of the keys, such as "font.wrap", in the config
+// file filename. Its comments and the keys it does not know about are kept,
+// missing keys are added and a missing file is created.
+func writeConfig(filename string, values map[string]interface{}) error {
+	var doc yaml.Node
+
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := setConfigValue(doc.Content[0], strings.Split(key, "."), values[key]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, b.Bytes())
+}
+
+// setConfigValue sets the value at the path of keys below the mapping node,
+// adding the mappings and keys which are missing.
+func setConfigValue(node *yaml.Node, keys []string, value interface{}) error {
+	// An empty section, such as "font:" with nothing under it, is null.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: node.HeadComment, LineComment: node.LineComment}
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return errors.New("not a mapping")
+	}
+
+	var child *yaml.Node
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == keys[0] {
+			child = node.Content[i+1]
+			break
+		}
+	}
+
+	if child == nil {
+		child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[0]}, child)
+	}
+
+	if len(keys) > 1 {
+		return setConfigValue(child, keys[1:], value)
+	}
+
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return err
+	}
+
+	// Replace the value but keep the comments around it.
+	v.HeadComment, v.LineComment, v.FootComment = child.HeadComment, child.LineComment, child.FootComment
+	*child = v
+
+	return nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		values map[string]interface{}
+		want   string
+	}{
+		{
+			name:   "comments are kept",
+			config: "# Notepad settings\nfont:\n  family: Monospace # the font\n  size: 10\n",
+			values: map[string]interface{}{"font.size": int64(12)},
+			want:   "# Notepad settings\nfont:\n  family: Monospace # the font\n  size: 12\n",
+		},
+		{
+			name:   "null section becomes a mapping",
+			config: "# Shown below the text.\nstatusbar:\n",
+			values: map[string]interface{}{"statusbar.enable": true},
+			want:   "# Shown below the text.\nstatusbar:\n  enable: true\n",
+		},
+		{
+			name:   "unknown keys are kept",
+			config: "plugins:\n  foo: bar\nfont:\n  family: Monospace\n  ligatures: on\n",
+			values: map[string]interface{}{"font.family": "Sans"},
+			want:   "plugins:\n  foo: bar\nfont:\n  family: Sans\n  ligatures: on\n",
+		},
+		{
+			name:   "missing keys are added",
+			config: "font:\n  family: Monospace\n",
+			values: map[string]interface{}{"font.wrap": true, "recent.size": 5},
+			want:   "font:\n  family: Monospace\n  wrap: true\nrecent:\n  size: 5\n",
+		},
+		{
+			name:   "empty file",
+			config: "",
+			values: map[string]interface{}{"font.size": int64(10)},
+			want:   "font:\n  size: 10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		filename := filepath.Join(t.TempDir(), ".notepad.yml")
+
+		if err := os.WriteFile(filename, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writeConfig(filename, tt.values); err != nil {
+			t.Errorf("%s: writeConfig() error: %s", tt.name, err)
+			continue
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != tt.want {
+			t.Errorf("%s: writeConfig() wrote\n%s\nwant\n%s", tt.name, data, tt.want)
+		}
+	}
+}
+
+func TestWriteConfigCreatesFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "go-notepad", "notepad.yml")
+
+	if err := writeConfig(filename, map[string]interface{}{"font.wrap": true}); err != nil {
+		t.Fatalf("writeConfig() error: %s", err)
+	}
+
+	c, err := readConfig(filename)
+	if err != nil {
+		t.Fatalf("failed reading written config: %s", err)
+	}
+
+	if !c.Font.Wrap {
+		t.Errorf("font.wrap = false, want true")
+	}
+}
+
+func TestSetConfigValueNotMapping(t *testing.T) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal([]byte("font: Monospace\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	err := setConfigValue(doc.Content[0], strings.Split("font.size", "."), 10)
+	if err == nil {
+		t.Errorf("setConfigValue() into a string did not fail")
+	}
+}
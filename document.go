@@ -0,0 +1,440 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+type (
+	// document is a file, or an unsaved Untitled text, opened in its own tab.
+	document struct {
+		app      *app
+		textView *textView
+		tabLabel *gtk.Label
+
+		openedFilename  string
+		hasChanges      bool
+		isFileOpened    bool
+		lineCount       int
+		lineOffsetCount int
+		encoding        *textEncoding
+		lineEnding      lineEnding
+		wrap            bool
+		readOnly        bool
+
+		// language is what the text is highlighted as, nil for plain text.
+		// languageChosen is set once it was picked from the menu, it is then
+		// no longer detected.
+		language       *language
+		languageChosen bool
+
+		// watchedFilename is the absolute path of the file on disk, loaded and
+		// seen its versions as tracked by the fileWatcher.
+		watchedFilename string
+		loaded          fileStamp
+		seen            fileStamp
+		prompting       bool
+
+		// closeWaiters are called when the tab is closed, see waitForClose.
+		closeWaiters []func()
+	}
+)
+
+func newDocument(app *app) *document {
+	d := &document{
+		app:            app,
+		openedFilename: defaultFilename,
+		lineCount:      1,
+		encoding:       encodingUTF8,
+		lineEnding:     defaultLineEnding(),
+	}
+
+	d.textView = newTextView(d)
+	d.tabLabel, _ = gtk.LabelNew("")
+	d.textView.gutter.SetFont(app.config.Font.Family, app.application.zoomed(app.config.Font.Size))
+	d.textView.gutter.SetVisible(app.lineNumbers)
+	d.SetWrap(app.config.Font.Wrap)
+	d.setupEvents()
+
+	return d
+}
+
+func (d *document) setupEvents() {
+	tb, _ := d.textView.GTKtextView.GetBuffer()
+	tb.Connect("mark-set", func(tb *gtk.TextBuffer, itr *gtk.TextIter) {
+		d.lineCount = itr.GetLine() + 1
+		d.lineOffsetCount = itr.GetLineOffset()
+
+		if d.isCurrent() {
+			d.app.updateSelectionMenu()
+			d.app.updateStatusBar()
+		}
+	})
+
+	tb.Connect("changed", func(tb *gtk.TextBuffer) {
+		d.hasChanges = true
+		d.UpdateTitle()
+	})
+
+	// Control and the mouse wheel zoom instead of scrolling.
+	d.textView.GTKtextView.Connect("scroll-event", func(_ *gtk.TextView, ev *gdk.Event) bool {
+		scroll := gdk.EventScrollNewFromEvent(ev)
+		if scroll.State()&gdk.CONTROL_MASK == 0 {
+			return false
+		}
+
+		ap := d.app.application
+
+		switch {
+		case scroll.Direction() == gdk.SCROLL_UP, scroll.Direction() == gdk.SCROLL_SMOOTH && scroll.DeltaY() < 0:
+			ap.SetZoom(ap.zoom + zoomStep)
+		case scroll.Direction() == gdk.SCROLL_DOWN, scroll.Direction() == gdk.SCROLL_SMOOTH && scroll.DeltaY() > 0:
+			ap.SetZoom(ap.zoom - zoomStep)
+		}
+
+		return true
+	})
+
+	d.textView.GTKtextView.Connect("drag-data-received", func(tv *gtk.TextView, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData, info uint, time uint32) {
+		// The text view would otherwise insert the data a second time.
+		tv.StopEmission("drag-data-received")
+
+		switch info {
+		case dropURIList:
+			d.app.OpenURIs(data.GetURIs())
+		case dropText:
+			d.textView.InsertAtLocation(x, y, data.GetText())
+		}
+	})
+}
+
+// onClose calls f once the document is closed.
+func (d *document) onClose(f func()) {
+	d.closeWaiters = append(d.closeWaiters, f)
+}
+
+// closed tells whoever waits for the document that it was closed.
+func (d *document) closed() {
+	waiters := d.closeWaiters
+	d.closeWaiters = nil
+
+	for _, f := range waiters {
+		f()
+	}
+}
+
+func (d *document) isCurrent() bool {
+	return d.app.doc == d
+}
+
+// isBlank reports whether the document is an untouched Untitled tab which
+// can be reused to open a file.
+func (d *document) isBlank() bool {
+	return !d.isFileOpened && !d.hasChanges && d.textView.Text() == ""
+}
+
+// Name is the file name shown in the tab and the window title.
+func (d *document) Name() string {
+	if d.readOnly {
+		return filepath.Base(d.openedFilename) + " [Read Only]"
+	}
+
+	return filepath.Base(d.openedFilename)
+}
+
+// UpdateTitle refreshes the tab label, marking unsaved documents with a "*",
+// and the window title when the document is current.
+func (d *document) UpdateTitle() {
+	name := d.Name()
+
+	if d.hasChanges {
+		name = "*" + name
+	}
+
+	d.tabLabel.SetText(name)
+	d.tabLabel.SetTooltipText(d.openedFilename)
+
+	if d.isCurrent() {
+		d.app.UpdateTitle()
+	}
+}
+
+// LoadFile opens filename, decoding it with enc or the detected encoding when
+// enc is nil. A file opened before is shown where it was left.
+func (d *document) LoadFile(filename string, enc *textEncoding) {
+	// Remember where the file being replaced, or reloaded, was left.
+	d.app.fileMetadata.Record(d)
+	md := d.app.fileMetadata.Get(filename)
+
+	if enc == nil && md != nil {
+		enc = encodingByName(md.Encoding)
+	}
+
+	enc, ending, mixed, err := d.textView.LoadSource(filename, enc)
+
+	// The document is left alone, so saving does not write its text over the
+	// file which failed to load.
+	if err != nil {
+		d.app.UnexpectedErrorMessageBox("Unexpected error loading file: %s\n\n%s", filename, err)
+		return
+	}
+
+	d.openedFilename = filename
+	d.app.recentFiles.Add(filename)
+
+	if md != nil {
+		md.apply(d)
+	}
+
+	d.encoding = enc
+	d.SetLineEnding(ending)
+	d.detectLanguage()
+
+	d.textView.history.Reset()
+	d.Watch(filename)
+	d.hasChanges = false
+	d.isFileOpened = true
+	d.UpdateTitle()
+	d.app.updateStatusBar()
+
+	if mixed {
+		dlg := gtk.MessageDialogNew(d.app.Win, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK, "%s has mixed line endings.", filepath.Base(filename))
+		dlg.FormatSecondaryText("They will all be saved as %s.", ending)
+		dlg.SetTitle(appName)
+		dlg.Run()
+		dlg.Destroy()
+	}
+}
+
+// Reload loads the file again after another program changed it, keeping its
+// encoding, the cursor and the scroll position. Unlike LoadFile it leaves
+// Recent Files alone and does not warn about mixed line endings again.
+func (d *document) Reload() error {
+	pos := d.textView.Position()
+	enc, ending, _, err := d.textView.LoadSource(d.openedFilename, d.encoding)
+
+	if err != nil {
+		return err
+	}
+
+	d.encoding = enc
+	d.SetLineEnding(ending)
+	d.detectLanguage()
+	d.textView.SetPosition(pos)
+
+	d.textView.history.Reset()
+	d.Watch(d.openedFilename)
+	d.hasChanges = false
+	d.UpdateTitle()
+	d.app.updateStatusBar()
+
+	return nil
+}
+
+// LoadData shows data, such as what was piped to stdin, as an Untitled
+// document, decoding it with enc or the detected encoding when enc is nil.
+func (d *document) LoadData(data []byte, enc *textEncoding) {
+	enc, ending, _, err := d.textView.LoadData(data, enc)
+
+	if err != nil {
+		d.app.UnexpectedErrorMessageBox("Unexpected error decoding text:\n\n%s", err)
+		enc, ending = encodingUTF8, defaultLineEnding()
+	}
+
+	d.encoding = enc
+	d.SetLineEnding(ending)
+	d.detectLanguage()
+
+	d.textView.history.Reset()
+	d.hasChanges = false
+	d.UpdateTitle()
+	d.app.updateStatusBar()
+}
+
+// NewFile names the empty document after filename, a file which does not
+// exist yet and is created when the document is saved.
+func (d *document) NewFile(filename string, enc *textEncoding) {
+	if enc != nil {
+		d.encoding = enc
+	}
+
+	d.openedFilename = filename
+	d.isFileOpened = true
+	d.Watch(filename)
+	d.detectLanguage()
+	d.UpdateTitle()
+	d.app.updateStatusBar()
+}
+
+// SetReadOnly stops the document from being edited. Saving it then asks for
+// a new file name.
+func (d *document) SetReadOnly(readOnly bool) {
+	d.readOnly = readOnly
+	d.textView.GTKtextView.SetEditable(!readOnly)
+	d.UpdateTitle()
+}
+
+// SetWrap turns word wrap on or off for the document.
+func (d *document) SetWrap(wrap bool) {
+	d.wrap = wrap
+	d.textView.WrapText(wrap)
+
+	if d.isCurrent() {
+		d.app.actions["word-wrap"].SetState(glib.VariantFromBoolean(wrap))
+	}
+}
+
+// SetLanguage highlights the document as l, or as plain text when l is nil.
+func (d *document) SetLanguage(l *language) {
+	d.language = l
+	d.textView.highlighter.SetLanguage(l)
+
+	if d.isCurrent() {
+		id := plainTextID
+		if l != nil {
+			id = l.ID
+		}
+
+		d.app.actions["language"].SetState(glib.VariantFromString(id))
+	}
+}
+
+// detectLanguage highlights the document as the language its name and text
+// look like, unless one was picked from the menu.
+func (d *document) detectLanguage() {
+	if d.languageChosen {
+		return
+	}
+
+	if l := detectLanguage(d.openedFilename, d.textView.Text()); l != d.language {
+		d.SetLanguage(l)
+	}
+}
+
+// SetLineEnding changes the line ending used when the document is saved.
+func (d *document) SetLineEnding(ending lineEnding) {
+	d.lineEnding = ending
+
+	if d.isCurrent() {
+		d.app.actions["line-ending"].SetState(glib.VariantFromString(ending.name()))
+		d.app.updateStatusBar()
+	}
+}
+
+// Save writes the document to its file, asking for a name when it has none,
+// and reports whether it was saved.
+func (d *document) Save() bool {
+	if !d.isFileOpened || d.readOnly {
+		return d.SaveAs()
+	}
+
+	if !d.confirmOverwrite() {
+		return false
+	}
+
+	err := d.textView.SaveSource(d.openedFilename, d.encoding, d.lineEnding)
+	if err != nil {
+		d.app.SaveErrorMessageBox(d.openedFilename, err)
+		return false
+	}
+
+	d.textView.history.MarkSaved()
+	d.Remember()
+	d.hasChanges = false
+	d.UpdateTitle()
+
+	return true
+}
+
+// SaveAs asks for a file name and encoding, writes the document to it and
+// reports whether it was saved.
+func (d *document) SaveAs() bool {
+	fc, _ := gtk.FileChooserNativeDialogNew("Save As...", d.app.Win, gtk.FILE_CHOOSER_ACTION_SAVE, "Save", "Cancel")
+	addEncodingChoice(&fc.FileChooser, false, d.encoding.Name)
+	response := fc.Run()
+	enc := chosenEncoding(&fc.FileChooser)
+	fc.Destroy()
+
+	filename := fc.GetFilename()
+
+	// Keeping the encoding keeps whether the file has a byte order mark.
+	if enc == nil || enc.Name == d.encoding.Name {
+		enc = d.encoding
+	}
+
+	if response != int(gtk.RESPONSE_ACCEPT) {
+		return false
+	}
+
+	if fileExist(filename) {
+		dlg := gtk.MessageDialogNew(d.app.Win, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK_CANCEL, "")
+		dlg.FormatSecondaryText("You are about to write to a already saved file! Are you sure you wish to do this?")
+		dlg.SetTitle(appName)
+		response := dlg.Run()
+		dlg.Destroy()
+
+		if response == gtk.RESPONSE_CANCEL || response == gtk.RESPONSE_DELETE_EVENT {
+			return false
+		}
+	}
+
+	err := d.textView.SaveSource(filename, enc, d.lineEnding)
+
+	if err != nil {
+		d.app.SaveErrorMessageBox(filename, err)
+		return false
+	}
+
+	d.textView.history.MarkSaved()
+	d.Watch(filename)
+	d.app.recentFiles.Add(filename)
+	d.encoding = enc
+	d.app.updateStatusBar()
+	d.openedFilename = filename
+	d.hasChanges = false
+	d.isFileOpened = true
+	d.detectLanguage()
+	d.UpdateTitle()
+
+	return true
+}
+
+func (d *document) displayUnsavedChangesMessagedialog() (response gtk.ResponseType) {
+	dlg := gtk.MessageDialogNew(
+		d.app.Win,
+		gtk.DIALOG_DESTROY_WITH_PARENT,
+		gtk.MESSAGE_WARNING,
+		gtk.BUTTONS_YES_NO,
+		"The text in the %s file has changed.",
+		d.openedFilename,
+	)
+	dlg.FormatSecondaryText("Do you want to save the changes?")
+	dlg.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dlg.SetTitle(appName)
+	response = dlg.Run()
+	dlg.Destroy()
+
+	return
+}
+
+// confirmClose offers to save unsaved changes and reports whether the
+// document may be closed.
+func (d *document) confirmClose() bool {
+	if !d.hasChanges {
+		return true
+	}
+
+	d.app.SetCurrentDocument(d)
+
+	switch d.displayUnsavedChangesMessagedialog() {
+	case gtk.RESPONSE_YES:
+		return d.Save()
+	case gtk.RESPONSE_NO:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gtk"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	encodingChoiceID = "encoding"
+	encodingAuto     = "auto"
+)
+
+type (
+	textEncoding struct {
+		Name     string
+		bom      []byte
+		encoding encoding.Encoding
+	}
+)
+
+var (
+	encodingUTF8    = &textEncoding{Name: "UTF-8", encoding: unicode.UTF8}
+	encodingUTF8BOM = &textEncoding{Name: "UTF-8 with BOM", bom: []byte{0xEF, 0xBB, 0xBF}, encoding: unicode.UTF8BOM}
+	encodingUTF16LE = &textEncoding{Name: "UTF-16 LE", bom: []byte{0xFF, 0xFE}, encoding: unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)}
+	encodingUTF16BE = &textEncoding{Name: "UTF-16 BE", bom: []byte{0xFE, 0xFF}, encoding: unicode.UTF16(unicode.BigEndian, unicode.UseBOM)}
+	encodingCP1252  = &textEncoding{Name: "Windows-1252", encoding: charmap.Windows1252}
+	encodingLatin1  = &textEncoding{Name: "ISO-8859-1", encoding: charmap.ISO8859_1}
+	encodingLatin9  = &textEncoding{Name: "ISO-8859-15", encoding: charmap.ISO8859_15}
+	encodingSJIS    = &textEncoding{Name: "Shift-JIS", encoding: japanese.ShiftJIS}
+	encodingEUCJP   = &textEncoding{Name: "EUC-JP", encoding: japanese.EUCJP}
+
+	// encodingUTF16LENoBOM and encodingUTF16BENoBOM read and write UTF-16
+	// files which have no byte order mark, so saving them does not add one.
+	encodingUTF16LENoBOM = &textEncoding{Name: "UTF-16 LE", encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)}
+	encodingUTF16BENoBOM = &textEncoding{Name: "UTF-16 BE", encoding: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)}
+
+	textEncodings = []*textEncoding{
+		encodingUTF8,
+		encodingUTF8BOM,
+		encodingUTF16LE,
+		encodingUTF16BE,
+		encodingCP1252,
+		encodingLatin1,
+		encodingLatin9,
+		encodingSJIS,
+		encodingEUCJP,
+	}
+)
+
+func encodingByName(name string) *textEncoding {
+	for _, e := range textEncodings {
+		if strings.EqualFold(e.Name, name) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// detectEncoding guesses the encoding of data from its byte order mark and,
+// failing that, from which encodings the bytes are valid in.
+func detectEncoding(data []byte) *textEncoding {
+	for _, e := range textEncodings {
+		if e.bom != nil && bytes.HasPrefix(data, e.bom) {
+			return e
+		}
+	}
+
+	// UTF-16 of ASCII text is valid UTF-8 too, full of NULs which would cut
+	// the text short in the buffer, so it is looked for first.
+	if e := detectUTF16(data); e != nil {
+		return e
+	}
+
+	if utf8.Valid(data) {
+		return encodingUTF8
+	}
+
+	if looksLikeEUCJP(data) {
+		return encodingEUCJP
+	}
+
+	if looksLikeShiftJIS(data) {
+		return encodingSJIS
+	}
+
+	// Windows-1252 is a superset of the printable ISO-8859-1 characters, so
+	// it is the safest guess for anything else.
+	return encodingCP1252
+}
+
+// detectUTF16 spots UTF-16 text without a byte order mark by the zero high
+// bytes of ASCII characters.
+func detectUTF16(data []byte) *textEncoding {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return nil
+	}
+
+	evenZeros, oddZeros := 0, 0
+
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	pairs := len(data) / 2
+
+	switch {
+	case oddZeros*5 > pairs*2 && evenZeros*10 < pairs:
+		return encodingUTF16LENoBOM
+	case evenZeros*5 > pairs*2 && oddZeros*10 < pairs:
+		return encodingUTF16BENoBOM
+	}
+
+	return nil
+}
+
+// forData returns the variant of e which matches whether data starts with a
+// byte order mark, so a UTF-16 file is saved with or without one as it was.
+func (e *textEncoding) forData(data []byte) *textEncoding {
+	if len(data) == 0 {
+		return e
+	}
+
+	switch e {
+	case encodingUTF16LE, encodingUTF16LENoBOM:
+		if bytes.HasPrefix(data, encodingUTF16LE.bom) {
+			return encodingUTF16LE
+		}
+
+		return encodingUTF16LENoBOM
+	case encodingUTF16BE, encodingUTF16BENoBOM:
+		if bytes.HasPrefix(data, encodingUTF16BE.bom) {
+			return encodingUTF16BE
+		}
+
+		return encodingUTF16BENoBOM
+	}
+
+	return e
+}
+
+// looksLikeEUCJP reports whether every non ASCII byte of data is part of an
+// EUC-JP multi byte character.
+func looksLikeEUCJP(data []byte) bool {
+	pairs := 0
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if c < 0x80 {
+			continue
+		}
+
+		// 0x8F starts a three byte JIS X 0212 character.
+		n := 1
+		if c == 0x8F {
+			n = 2
+		}
+
+		if (c < 0xA1 && c != 0x8E && c != 0x8F) || i+n >= len(data) {
+			return false
+		}
+
+		for _, t := range data[i+1 : i+n+1] {
+			if t < 0xA1 || t == 0xFF {
+				return false
+			}
+		}
+
+		pairs++
+		i += n
+	}
+
+	return pairs > 0
+}
+
+// looksLikeShiftJIS reports whether data is valid Shift-JIS made up mostly of
+// double byte characters, which single byte Latin text rarely is. Latin text
+// can pair an accented letter with the ASCII letter after it, so only pairs
+// with a high trail byte, or a lead byte of the punctuation or katakana rows
+// which are unused or rare in Windows-1252, count as Japanese.
+func looksLikeShiftJIS(data []byte) bool {
+	pairs, likely := 0, 0
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c < 0x80, c >= 0xA1 && c <= 0xDF:
+			continue
+		case c >= 0x81 && c <= 0x9F, c >= 0xE0 && c <= 0xFC:
+			if i+1 == len(data) {
+				return false
+			}
+
+			t := data[i+1]
+			if t < 0x40 || t == 0x7F || t > 0xFC {
+				return false
+			}
+
+			pairs++
+			if t >= 0x80 || c == 0x81 || c == 0x83 {
+				likely++
+			}
+			i++
+		default:
+			return false
+		}
+	}
+
+	return pairs > 0 && likely*2 > pairs
+}
+
+// Decode converts data into UTF-8 text for the GTK buffer, dropping any byte
+// order mark.
+func (e *textEncoding) Decode(data []byte) (string, error) {
+	text, err := e.encoding.NewDecoder().Bytes(data)
+
+	if err != nil {
+		return "", fmt.Errorf("unable to decode file as %s: %w", e.Name, err)
+	}
+
+	return string(text), nil
+}
+
+// Encode converts the UTF-8 text of the buffer into the encoding, writing a
+// byte order mark when the encoding has one.
+func (e *textEncoding) Encode(text string) ([]byte, error) {
+	data, err := e.encoding.NewEncoder().Bytes([]byte(text))
+
+	if err != nil {
+		return nil, fmt.Errorf("the text contains characters which can not be saved as %s, try saving it as UTF-8 instead: %w", e.Name, err)
+	}
+
+	return data, nil
+}
+
+// addEncodingChoice adds the XP Notepad style encoding dropdown to a file
+// chooser, optionally with an auto-detect entry for opening files.
+func addEncodingChoice(fc *gtk.FileChooser, auto bool, selected string) {
+	var options, labels []string
+
+	if auto {
+		options = append(options, encodingAuto)
+		labels = append(labels, "Auto-Detect")
+	}
+
+	for _, e := range textEncodings {
+		options = append(options, e.Name)
+		labels = append(labels, e.Name)
+	}
+
+	fc.AddChoice(encodingChoiceID, "Encoding:", options, labels)
+	fc.SetChoice(encodingChoiceID, selected)
+}
+
+// chosenEncoding returns the encoding picked in a file chooser or nil to
+// auto-detect it.
+func chosenEncoding(fc *gtk.FileChooser) *textEncoding {
+	return encodingByName(fc.GetChoice(encodingChoiceID))
+}
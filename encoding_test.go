@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const japaneseText = "日本語のテキスト、カタカナとひらがな。\n"
+
+func encodeText(t *testing.T, e encoding.Encoding, text string) []byte {
+	t.Helper()
+
+	data, err := e.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("failed encoding test text: %s", err)
+	}
+
+	return data
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *textEncoding
+	}{
+		{"empty", nil, encodingUTF8},
+		{"ascii", []byte("hello world\n"), encodingUTF8},
+		{"utf-8", []byte(japaneseText), encodingUTF8},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "hello"...), encodingUTF8BOM},
+		{"utf-16 le bom", encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "hello"), encodingUTF16LE},
+		{"utf-16 be bom", encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "hello"), encodingUTF16BE},
+		{"utf-16 le without bom", encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "hello world\n"), encodingUTF16LENoBOM},
+		{"utf-16 be without bom", encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "hello world\n"), encodingUTF16BENoBOM},
+		{"shift-jis", encodeText(t, japanese.ShiftJIS, japaneseText), encodingSJIS},
+		{"euc-jp", encodeText(t, japanese.EUCJP, japaneseText), encodingEUCJP},
+		{"windows-1252", encodeText(t, charmap.Windows1252, "Café naïve “quoted” – 10€\n"), encodingCP1252},
+	}
+
+	for _, tt := range tests {
+		if got := detectEncoding(tt.data); got != tt.want {
+			t.Errorf("%s: detectEncoding() = %s, want %s", tt.name, got.Name, tt.want.Name)
+		}
+	}
+}
+
+func TestUTF16RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		enc  *textEncoding
+	}{
+		{"le bom", encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "hello world\n"), nil},
+		{"le without bom", encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "hello world\n"), nil},
+		{"be bom", encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "hello world\n"), nil},
+		{"be without bom", encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "hello world\n"), nil},
+		{"le without bom forced", encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "日本語"), encodingUTF16LE},
+		{"be bom forced", encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "日本語"), encodingUTF16BENoBOM},
+	}
+
+	for _, tt := range tests {
+		enc := tt.enc
+		if enc == nil {
+			enc = detectEncoding(tt.data)
+		}
+
+		enc = enc.forData(tt.data)
+
+		text, err := enc.Decode(tt.data)
+		if err != nil {
+			t.Errorf("%s: Decode() error: %s", tt.name, err)
+			continue
+		}
+
+		data, err := enc.Encode(text)
+		if err != nil {
+			t.Errorf("%s: Encode() error: %s", tt.name, err)
+			continue
+		}
+
+		if !bytes.Equal(data, tt.data) {
+			t.Errorf("%s: saved % x, want % x", tt.name, data, tt.data)
+		}
+	}
+}
+
+func TestLooksLikeShiftJIS(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"ascii", []byte("hello"), false},
+		{"shift-jis", encodeText(t, japanese.ShiftJIS, japaneseText), true},
+		{"euc-jp", encodeText(t, japanese.EUCJP, "テキスト"), false},
+		{"windows-1252", encodeText(t, charmap.Windows1252, "Café naïve"), false},
+		{"cut short", encodeText(t, japanese.ShiftJIS, "日本")[:3], false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeShiftJIS(tt.data); got != tt.want {
+			t.Errorf("%s: looksLikeShiftJIS() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLooksLikeEUCJP(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"ascii", []byte("hello"), false},
+		{"euc-jp", encodeText(t, japanese.EUCJP, japaneseText), true},
+		{"jis x 0212", []byte{'a', 0x8F, 0xB0, 0xA1, 'b'}, true},
+		{"shift-jis", encodeText(t, japanese.ShiftJIS, japaneseText), false},
+		{"windows-1252", encodeText(t, charmap.Windows1252, "Café naïve"), false},
+		{"cut short", encodeText(t, japanese.EUCJP, "日本")[:3], false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeEUCJP(tt.data); got != tt.want {
+			t.Errorf("%s: looksLikeEUCJP() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
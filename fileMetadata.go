@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	fileMetadataState = "files.json"
+
+	// maxFileMetadata bounds the store, the least recently used files are
+	// forgotten first.
+	maxFileMetadata = 500
+)
+
+type (
+	// fileMetadata is what is remembered about a file after it is closed so
+	// it opens where it was left.
+	fileMetadata struct {
+		Line     int
+		Column   int
+		TopLine  int
+		Encoding string
+		Wrap     bool
+		Used     time.Time
+	}
+
+	// fileMetadataStore maps absolute file names to their metadata.
+	fileMetadataStore struct {
+		files map[string]*fileMetadata
+	}
+)
+
+func newFileMetadataStore() *fileMetadataStore {
+	m := &fileMetadataStore{}
+	m.files = m.load()
+
+	return m
+}
+
+func (m *fileMetadataStore) load() map[string]*fileMetadata {
+	files := make(map[string]*fileMetadata)
+
+	if err := loadState(fileMetadataState, &files); err != nil {
+		fmt.Printf("failed loading file metadata: %s\n", err)
+	}
+
+	if files == nil {
+		files = make(map[string]*fileMetadata)
+	}
+
+	return files
+}
+
+// Get returns the metadata of filename or nil when it was never recorded.
+func (m *fileMetadataStore) Get(filename string) *fileMetadata {
+	return m.files[absPath(filename)]
+}
+
+// Record remembers the state of the file opened in d.
+func (m *fileMetadataStore) Record(d *document) {
+	if !d.isFileOpened {
+		return
+	}
+
+	line, col := d.textView.CursorLineColumn()
+
+	m.files[absPath(d.openedFilename)] = &fileMetadata{
+		Line:     line,
+		Column:   col,
+		TopLine:  d.textView.TopLine(),
+		Encoding: d.encoding.Name,
+		Wrap:     d.wrap,
+		Used:     time.Now(),
+	}
+}
+
+// Save writes the store, keeping entries other running editors recorded
+// meanwhile, and evicts the oldest entries beyond maxFileMetadata.
+func (m *fileMetadataStore) Save() {
+	for filename, md := range m.load() {
+		if cur, ok := m.files[filename]; !ok || md.Used.After(cur.Used) {
+			m.files[filename] = md
+		}
+	}
+
+	if len(m.files) > maxFileMetadata {
+		names := make([]string, 0, len(m.files))
+		for filename := range m.files {
+			names = append(names, filename)
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			return m.files[names[i]].Used.After(m.files[names[j]].Used)
+		})
+
+		for _, filename := range names[maxFileMetadata:] {
+			delete(m.files, filename)
+		}
+	}
+
+	if err := saveState(fileMetadataState, m.files); err != nil {
+		fmt.Printf("failed saving file metadata: %s\n", err)
+	}
+}
+
+// SaveFileMetadata records every open document and saves the store, done
+// when the window closes.
+func (a *app) SaveFileMetadata() {
+	for _, d := range a.documents {
+		a.fileMetadata.Record(d)
+	}
+
+	a.fileMetadata.Save()
+}
+
+// apply moves the cursor and view of d to where the file was left.
+func (md *fileMetadata) apply(d *document) {
+	d.SetWrap(md.Wrap)
+	d.textView.GoToLineColumn(md.Line, md.Column)
+	d.textView.ScrollToLine(md.TopLine)
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// umask is read once at startup. Setting it is the only way to read it, and
+// it is shared by the whole process, so doing that later would race with the
+// other threads creating files.
+var umask = func() int {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+
+	return mask
+}()
+
+// defaultFileMode is the mode new files get, honoring the umask like
+// os.WriteFile does.
+func defaultFileMode() fs.FileMode {
+	return fs.FileMode(0666 &^ umask)
+}
+
+func copyFileOwner(filename string, info fs.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(filename, int(st.Uid), int(st.Gid))
+	}
+}
+
+// syncDir makes sure a rename inside dir has reached the disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	d.Sync()
+	d.Close()
+}
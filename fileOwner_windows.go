@@ -0,0 +1,13 @@
+package main
+
+import "io/fs"
+
+func defaultFileMode() fs.FileMode {
+	return 0666
+}
+
+// Windows files inherit their ACLs from the directory, there is no owner to copy.
+func copyFileOwner(filename string, info fs.FileInfo) {}
+
+// Windows can not sync directories, the rename is already durable.
+func syncDir(dir string) {}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+type (
+	// fileStamp identifies a version of a file on disk.
+	fileStamp struct {
+		modTime time.Time
+		size    int64
+		missing bool
+	}
+
+	// fileWatcher notices when opened files are changed by another program.
+	// Directories are watched rather than the files so atomic saves, which
+	// replace the file, are seen too.
+	fileWatcher struct {
+		application *application
+		watcher     *fsnotify.Watcher
+		dirs        map[string]int
+	}
+)
+
+func statFile(filename string) fileStamp {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{missing: errors.Is(err, fs.ErrNotExist)}
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+func newFileWatcher(ap *application) *fileWatcher {
+	w := &fileWatcher{
+		application: ap,
+		dirs:        make(map[string]int),
+	}
+
+	var err error
+	w.watcher, err = fsnotify.NewWatcher()
+
+	if err != nil {
+		fmt.Printf("file changes will not be detected: %s\n", err)
+		return w
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *fileWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			name := filepath.Clean(event.Name)
+			glib.IdleAdd(func() {
+				for _, d := range w.application.documents() {
+					if name == d.watchedFilename {
+						d.checkDisk()
+					}
+				}
+			})
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			fmt.Printf("file watcher error: %s\n", err)
+		}
+	}
+}
+
+// add watches dir until every document in it has called remove.
+func (w *fileWatcher) add(dir string) {
+	if w.watcher == nil {
+		return
+	}
+
+	if w.dirs[dir] == 0 {
+		if err := w.watcher.Add(dir); err != nil {
+			fmt.Printf("unable to watch %s: %s\n", dir, err)
+		}
+	}
+
+	w.dirs[dir]++
+}
+
+func (w *fileWatcher) remove(dir string) {
+	if w.watcher == nil || w.dirs[dir] == 0 {
+		return
+	}
+
+	w.dirs[dir]--
+
+	if w.dirs[dir] == 0 {
+		delete(w.dirs, dir)
+		w.watcher.Remove(dir)
+	}
+}
+
+// Watch starts watching filename for the document, an empty filename stops
+// watching.
+func (d *document) Watch(filename string) {
+	filename = absPath(filename)
+
+	if filename != d.watchedFilename {
+		if d.watchedFilename != "" {
+			d.app.fileWatcher.remove(filepath.Dir(d.watchedFilename))
+		}
+
+		if filename != "" {
+			d.app.fileWatcher.add(filepath.Dir(filename))
+		}
+
+		d.watchedFilename = filename
+	}
+
+	d.Remember()
+}
+
+// Remember records the file on disk as the version the buffer is based on,
+// done after it has been loaded or saved.
+func (d *document) Remember() {
+	if d.watchedFilename == "" {
+		return
+	}
+
+	d.loaded = statFile(d.watchedFilename)
+	d.seen = d.loaded
+}
+
+// ChangedOnDisk reports whether the file was modified since it was loaded or
+// last saved.
+func (d *document) ChangedOnDisk() bool {
+	if d.watchedFilename == "" {
+		return false
+	}
+
+	stamp := statFile(d.watchedFilename)
+
+	return !stamp.missing && stamp != d.loaded
+}
+
+func (d *document) checkDisk() {
+	// Prompting runs a nested main loop, more events may arrive meanwhile.
+	if d.prompting {
+		return
+	}
+
+	stamp := statFile(d.watchedFilename)
+	if stamp == d.seen {
+		return
+	}
+
+	d.seen = stamp
+
+	if stamp.missing {
+		// Keep the text and let Save bring the file back.
+		d.textView.history.ForgetSavePoint()
+		d.hasChanges = true
+		d.UpdateTitle()
+		d.app.displayMessage(fmt.Sprintf("%s was deleted by another program", d.Name()))
+		return
+	}
+
+	if !d.hasChanges {
+		d.reload()
+		return
+	}
+
+	d.app.SetCurrentDocument(d)
+
+	d.prompting = true
+	response := d.displayReloadMessageDialog()
+	d.prompting = false
+
+	if response == gtk.RESPONSE_YES {
+		d.reload()
+	}
+}
+
+func (d *document) reload() {
+	if err := d.Reload(); err != nil {
+		d.app.UnexpectedErrorMessageBox("Unexpected error reloading file: %s\n\n%s", d.openedFilename, err)
+		return
+	}
+
+	d.app.displayMessage(fmt.Sprintf("%s was reloaded", d.Name()))
+}
+
+func (d *document) displayReloadMessageDialog() gtk.ResponseType {
+	dlg := gtk.MessageDialogNew(
+		d.app.Win,
+		gtk.DIALOG_DESTROY_WITH_PARENT,
+		gtk.MESSAGE_QUESTION,
+		gtk.BUTTONS_YES_NO,
+		"%s has been modified by another program.",
+		d.Name(),
+	)
+	dlg.FormatSecondaryText("Do you want to reload it and lose the changes made in %s?", appName)
+	dlg.SetTitle(appName)
+	dlg.SetDefaultResponse(gtk.RESPONSE_NO)
+	response := dlg.Run()
+	dlg.Destroy()
+
+	return response
+}
+
+// confirmOverwrite asks before saving over a file which was modified by
+// another program since it was loaded.
+func (d *document) confirmOverwrite() bool {
+	if !d.ChangedOnDisk() {
+		return true
+	}
+
+	dlg := gtk.MessageDialogNew(
+		d.app.Win,
+		gtk.DIALOG_DESTROY_WITH_PARENT,
+		gtk.MESSAGE_WARNING,
+		gtk.BUTTONS_OK_CANCEL,
+		"%s has been modified by another program.",
+		d.Name(),
+	)
+	dlg.FormatSecondaryText("Saving will overwrite the newer version on disk. Are you sure you wish to do this?")
+	dlg.SetTitle(appName)
+	dlg.SetDefaultResponse(gtk.RESPONSE_CANCEL)
+	response := dlg.Run()
+	dlg.Destroy()
+
+	return response == gtk.RESPONSE_OK
+}
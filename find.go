@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	responseFindNext gtk.ResponseType = iota + 1
+	responseReplace
+	responseReplaceAll
+)
+
+type (
+	// findDialog backs both the Find and the Replace dialogs, the latter just
+	// adds the replacement entry and buttons.
+	findDialog struct {
+		app            *app
+		replace        bool
+		dialog         *gtk.Dialog
+		queryEntry     *gtk.Entry
+		replaceEntry   *gtk.Entry
+		wholeWordCheck *gtk.CheckButton
+		matchCaseCheck *gtk.CheckButton
+		regexCheck     *gtk.CheckButton
+		wrapCheck      *gtk.CheckButton
+		upRadio        *gtk.RadioButton
+		errorLabel     *gtk.Label
+	}
+)
+
+func newFindDialog(app *app, replace bool) *findDialog {
+	f := &findDialog{
+		app:     app,
+		replace: replace,
+	}
+
+	d, _ := gtk.DialogNew()
+	d.SetTitle("Find")
+	d.SetTransientFor(app.Win)
+	d.SetResizable(false)
+	f.dialog = d
+
+	if replace {
+		d.SetTitle("Replace")
+	}
+
+	b, _ := d.GetContentArea()
+	b.SetSpacing(5)
+	b.SetMarginTop(10)
+	b.SetMarginStart(10)
+	b.SetMarginEnd(10)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+
+	label, _ := gtk.LabelNew("Find what:")
+	label.SetHAlign(gtk.ALIGN_START)
+	f.queryEntry, _ = gtk.EntryNew()
+	f.queryEntry.SetHExpand(true)
+	f.queryEntry.SetActivatesDefault(true)
+
+	grid.Attach(label, 0, 0, 1, 1)
+	grid.Attach(f.queryEntry, 1, 0, 2, 1)
+
+	row := 1
+
+	if replace {
+		replaceLabel, _ := gtk.LabelNew("Replace with:")
+		replaceLabel.SetHAlign(gtk.ALIGN_START)
+		f.replaceEntry, _ = gtk.EntryNew()
+		f.replaceEntry.SetActivatesDefault(true)
+
+		grid.Attach(replaceLabel, 0, row, 1, 1)
+		grid.Attach(f.replaceEntry, 1, row, 2, 1)
+		row++
+	}
+
+	f.wholeWordCheck, _ = gtk.CheckButtonNewWithLabel("Match whole word only")
+	f.matchCaseCheck, _ = gtk.CheckButtonNewWithLabel("Match case")
+	f.regexCheck, _ = gtk.CheckButtonNewWithLabel("Regular expression")
+	f.wrapCheck, _ = gtk.CheckButtonNewWithLabel("Wrap around")
+
+	grid.Attach(f.wholeWordCheck, 0, row, 2, 1)
+	grid.Attach(f.matchCaseCheck, 0, row+1, 2, 1)
+	grid.Attach(f.regexCheck, 0, row+2, 2, 1)
+	grid.Attach(f.wrapCheck, 0, row+3, 2, 1)
+
+	if !replace {
+		direction, _ := gtk.FrameNew("Direction")
+		directionBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+		f.upRadio, _ = gtk.RadioButtonNewWithLabel(nil, "Up")
+		down, _ := gtk.RadioButtonNewWithLabelFromWidget(f.upRadio, "Down")
+		down.SetActive(true)
+		directionBox.PackStart(f.upRadio, false, false, 0)
+		directionBox.PackStart(down, false, false, 0)
+		direction.Add(directionBox)
+
+		grid.Attach(direction, 2, row, 1, 4)
+	}
+
+	// Shows why a regular expression does not compile while it is typed.
+	f.errorLabel, _ = gtk.LabelNew("")
+	f.errorLabel.SetHAlign(gtk.ALIGN_START)
+	f.errorLabel.SetLineWrap(true)
+	f.errorLabel.SetNoShowAll(true)
+	grid.Attach(f.errorLabel, 0, row+4, 3, 1)
+
+	b.PackStart(grid, true, true, 0)
+
+	d.AddButton("Find Next", responseFindNext)
+
+	if replace {
+		d.AddButton("Replace", responseReplace)
+		d.AddButton("Replace All", responseReplaceAll)
+	}
+
+	d.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	d.SetDefaultResponse(responseFindNext)
+	f.setResponsesSensitive(false)
+
+	f.queryEntry.Connect("changed", f.validate)
+	f.wholeWordCheck.Connect("toggled", f.validate)
+	f.matchCaseCheck.Connect("toggled", f.validate)
+	f.regexCheck.Connect("toggled", f.validate)
+
+	d.Connect("response", func(_ *gtk.Dialog, response gtk.ResponseType) {
+		switch response {
+		case responseFindNext:
+			app.FindNext(f.options())
+		case responseReplace:
+			app.Replace(f.options(), f.replacement())
+		case responseReplaceAll:
+			app.ReplaceAll(f.options(), f.replacement())
+		default:
+			d.Hide()
+		}
+	})
+
+	// The dialog is reused between searches, so never let it be destroyed.
+	d.Connect("delete-event", func() bool {
+		return true
+	})
+
+	return f
+}
+
+func (f *findDialog) setResponsesSensitive(sensitive bool) {
+	f.dialog.SetResponseSensitive(responseFindNext, sensitive)
+
+	if f.replace {
+		f.dialog.SetResponseSensitive(responseReplace, sensitive)
+		f.dialog.SetResponseSensitive(responseReplaceAll, sensitive)
+	}
+}
+
+// validate disables searching for an empty query and reports patterns that
+// do not compile right below the options.
+func (f *findDialog) validate() {
+	opts := f.options()
+	_, err := opts.compile()
+
+	if opts.query == "" || err == nil {
+		f.errorLabel.Hide()
+		f.setResponsesSensitive(opts.query != "")
+		return
+	}
+
+	f.errorLabel.SetMarkup(`<span foreground="red">` + html.EscapeString(err.Error()) + `</span>`)
+	f.errorLabel.Show()
+	f.setResponsesSensitive(false)
+}
+
+func (f *findDialog) options() searchOptions {
+	query, _ := f.queryEntry.GetText()
+
+	return searchOptions{
+		query:     query,
+		matchCase: f.matchCaseCheck.GetActive(),
+		wholeWord: f.wholeWordCheck.GetActive(),
+		regex:     f.regexCheck.GetActive(),
+		up:        f.upRadio != nil && f.upRadio.GetActive(),
+		wrap:      f.wrapCheck.GetActive(),
+	}
+}
+
+func (f *findDialog) replacement() string {
+	if f.replaceEntry == nil {
+		return ""
+	}
+
+	text, _ := f.replaceEntry.GetText()
+
+	return text
+}
+
+func (f *findDialog) Show() {
+	if sel := f.app.doc.textView.SelectedText(); sel != "" && !strings.Contains(sel, "\n") {
+		f.queryEntry.SetText(sel)
+	}
+
+	f.dialog.ShowAll()
+	f.dialog.Present()
+	f.queryEntry.GrabFocus()
+}
+
+func (a *app) ShowFindDialog() {
+	if a.findDialog == nil {
+		a.findDialog = newFindDialog(a, false)
+	}
+
+	a.findDialog.Show()
+}
+
+func (a *app) ShowReplaceDialog() {
+	if a.replaceDialog == nil {
+		a.replaceDialog = newFindDialog(a, true)
+	}
+
+	a.replaceDialog.Show()
+}
+
+// FindNext searches the text view using opts and remembers them so Find Next
+// (F3) can repeat the search without reopening the dialog.
+func (a *app) FindNext(opts searchOptions) {
+	a.search = opts
+
+	found, err := a.doc.textView.Find(opts)
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error searching for \"%s\":\n\n%s", opts.query, err)
+		return
+	}
+
+	if !found {
+		a.displayCannotFindMessage(opts.query)
+	}
+}
+
+// Replace replaces the current selection when it matches opts and moves on to
+// the next match.
+func (a *app) Replace(opts searchOptions, replacement string) {
+	a.search = opts
+
+	if a.doc.readOnly {
+		a.FindNext(opts)
+		return
+	}
+
+	found, err := a.doc.textView.Replace(opts, replacement)
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error replacing \"%s\":\n\n%s", opts.query, err)
+		return
+	}
+
+	if !found {
+		a.displayCannotFindMessage(opts.query)
+	}
+}
+
+func (a *app) ReplaceAll(opts searchOptions, replacement string) {
+	a.search = opts
+
+	if a.doc.readOnly {
+		a.displayMessage(fmt.Sprintf("Cannot replace \"%s\", the document is opened read only", opts.query))
+		return
+	}
+
+	count, err := a.doc.textView.ReplaceAll(opts, replacement)
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error replacing \"%s\":\n\n%s", opts.query, err)
+		return
+	}
+
+	if count == 0 {
+		a.displayCannotFindMessage(opts.query)
+		return
+	}
+
+	a.displayMessage(fmt.Sprintf("Replaced %d occurrence(s) of \"%s\"", count, opts.query))
+}
+
+func (a *app) displayCannotFindMessage(query string) {
+	a.displayFindMessage(fmt.Sprintf("Cannot find \"%s\"", query))
+}
+
+// displayMessage shows message in the status bar, or in a dialog when the
+// status bar is hidden, as it is by default, so it is always seen.
+func (a *app) displayMessage(message string) {
+	if a.statusBar.visible {
+		a.statusBar.SetMessage(message)
+	} else {
+		a.displayFindMessage(message)
+	}
+}
+
+// displayFindMessage shows message over the Find or Replace dialog, or the
+// window when neither is open.
+func (a *app) displayFindMessage(message string) {
+	var parent gtk.IWindow = a.Win
+
+	for _, f := range []*findDialog{a.findDialog, a.replaceDialog} {
+		if f != nil && f.dialog.IsVisible() {
+			parent = f.dialog
+		}
+	}
+
+	d := gtk.MessageDialogNew(parent, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_INFO, gtk.BUTTONS_OK, "%s", message)
+	d.SetTitle(appName)
+	d.Run()
+	d.Destroy()
+}
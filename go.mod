@@ -5,3 +5,9 @@ go 1.17
 require github.com/gotk3/gotk3 v0.6.3
 
 require gopkg.in/yaml.v3 v3.0.1
+
+require golang.org/x/text v0.13.0
+
+require github.com/fsnotify/fsnotify v1.6.0
+
+require golang.org/x/sys v0.5.0 // indirect
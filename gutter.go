@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// gutterPadding is the space in pixels either side of the line numbers.
+const gutterPadding = 6
+
+type (
+	// gutter shows the line numbers next to a text view. Clicking a number
+	// selects the line and dragging over them selects a range of lines.
+	gutter struct {
+		textView *textView
+		area     *gtk.DrawingArea
+		font     *pango.FontDescription
+		width    int
+
+		// anchor is the line the last selection made in the gutter started
+		// on, which dragging and shift-click extend, and selection the
+		// offsets it covers, to tell whether it was changed since.
+		anchor    int
+		selection [2]int
+		dragging  bool
+	}
+)
+
+func newGutter(t *textView) *gutter {
+	area, err := gtk.DrawingAreaNew()
+
+	if err != nil {
+		log.Fatal("failed setting up gtk gutter: ", err)
+	}
+
+	g := &gutter{
+		textView: t,
+		area:     area,
+		font:     pango.FontDescriptionFromString("Monospace 10"),
+		anchor:   -1,
+	}
+
+	// Only shown when line numbers are turned on, not by ShowAll.
+	area.SetNoShowAll(true)
+	area.AddEvents(int(gdk.BUTTON_PRESS_MASK | gdk.BUTTON_RELEASE_MASK | gdk.BUTTON1_MOTION_MASK))
+
+	if style, err := area.GetStyleContext(); err == nil {
+		style.AddClass("gutter")
+	}
+
+	area.Connect("draw", g.draw)
+	area.Connect("button-press-event", g.buttonPress)
+	area.Connect("motion-notify-event", g.motionNotify)
+	area.Connect("button-release-event", func() {
+		g.dragging = false
+	})
+
+	redraw := func() {
+		area.QueueDraw()
+	}
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	buff.Connect("changed", redraw)
+	buff.Connect("mark-set", redraw)
+	t.GTKtextView.Connect("size-allocate", redraw)
+	t.scrolled.GetVAdjustment().Connect("value-changed", redraw)
+
+	return g
+}
+
+// SetFont draws the line numbers in the font of the text.
+func (g *gutter) SetFont(font string, size float64) {
+	g.font = pango.FontDescriptionFromString(fmt.Sprintf("%s %g", font, size))
+	g.area.QueueDraw()
+}
+
+// SetVisible shows or hides the gutter.
+func (g *gutter) SetVisible(visible bool) {
+	g.area.SetVisible(visible)
+}
+
+func (g *gutter) draw(area *gtk.DrawingArea, cr *cairo.Context) bool {
+	tv := g.textView.GTKtextView
+	buff, _ := tv.GetBuffer()
+	style, _ := area.GetStyleContext()
+
+	layout := pango.CairoCreateLayout(cr)
+	layout.SetFontDescription(g.font)
+
+	// Room for the digits of the last line, and at least two of them so the
+	// text does not move about in short documents.
+	digits := len(strconv.Itoa(buff.GetLineCount()))
+	if digits < 2 {
+		digits = 2
+	}
+
+	layout.SetText(strings.Repeat("0", digits), -1)
+	textWidth, _ := layout.GetSize()
+	width := textWidth/pango.SCALE + 2*gutterPadding
+
+	if width != g.width {
+		g.width = width
+		area.SetSizeRequest(width, -1)
+	}
+
+	height := float64(area.GetAllocatedHeight())
+	gtk.RenderBackground(style, cr, 0, 0, float64(width), height)
+
+	fg := style.GetColor(gtk.STATE_FLAG_NORMAL)
+	red, green, blue := fg.GetRed(), fg.GetGreen(), fg.GetBlue()
+
+	rect := tv.GetVisibleRect()
+	bottom := rect.GetY() + rect.GetHeight()
+	cursor := buff.GetIterAtMark(buff.GetInsert()).GetLine()
+	iter, _ := tv.GetLineAtY(rect.GetY())
+
+	for {
+		y, lineHeight := tv.GetLineYrange(iter)
+		if y > bottom {
+			break
+		}
+
+		_, top := tv.BufferToWindowCoords(gtk.TEXT_WINDOW_WIDGET, 0, y)
+		line := iter.GetLine()
+
+		layout.SetText(strconv.Itoa(line+1), -1)
+		numberWidth, _ := layout.GetSize()
+
+		if line == cursor {
+			cr.SetSourceRGBA(red, green, blue, 0.1)
+			cr.Rectangle(0, float64(top), float64(width), float64(lineHeight))
+			cr.Fill()
+			cr.SetSourceRGBA(red, green, blue, 1)
+		} else {
+			cr.SetSourceRGBA(red, green, blue, 0.5)
+		}
+
+		cr.MoveTo(float64(width-gutterPadding-numberWidth/pango.SCALE), float64(top))
+		pango.CairoShowLayout(cr, layout)
+
+		if !iter.ForwardLine() {
+			break
+		}
+	}
+
+	return true
+}
+
+// lineAtY returns the line next to y in the gutter.
+func (g *gutter) lineAtY(y float64) int {
+	tv := g.textView.GTKtextView
+	_, by := tv.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, 0, int(y))
+	iter, _ := tv.GetLineAtY(by)
+
+	return iter.GetLine()
+}
+
+func (g *gutter) buttonPress(_ *gtk.DrawingArea, ev *gdk.Event) bool {
+	b := gdk.EventButtonNewFromEvent(ev)
+	if b.Button() != gdk.BUTTON_PRIMARY || b.Type() != gdk.EVENT_BUTTON_PRESS {
+		return false
+	}
+
+	line := g.lineAtY(b.Y())
+
+	// Shift-click extends the lines last selected in the gutter, or else
+	// selects from the line of the cursor.
+	if gdk.ModifierType(b.State())&gdk.SHIFT_MASK == 0 {
+		g.anchor = line
+	} else if g.anchor < 0 || g.selectionOffsets() != g.selection {
+		g.anchor, _ = g.textView.CursorLineColumn()
+	}
+
+	g.dragging = true
+	g.selectLines(line)
+	g.textView.GTKtextView.GrabFocus()
+
+	return true
+}
+
+func (g *gutter) motionNotify(_ *gtk.DrawingArea, ev *gdk.Event) bool {
+	if !g.dragging {
+		return false
+	}
+
+	_, y := gdk.EventMotionNewFromEvent(ev).MotionVal()
+	g.selectLines(g.lineAtY(y))
+
+	return true
+}
+
+// selectLines selects the lines from the anchor to line.
+func (g *gutter) selectLines(line int) {
+	g.textView.SelectLines(g.anchor, line)
+	g.selection = g.selectionOffsets()
+}
+
+func (g *gutter) selectionOffsets() [2]int {
+	buff, _ := g.textView.GTKtextView.GetBuffer()
+	start, end, _ := buff.GetSelectionBounds()
+
+	return [2]int{start.GetOffset(), end.GetOffset()}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	// highlightDelay is how long typing has to pause, in milliseconds, before
+	// the text is highlighted again.
+	highlightDelay = 150
+
+	// highlightMaxSize is the size in bytes above which text is left plain,
+	// it would take too long to highlight on every change.
+	highlightMaxSize = 2 << 20
+)
+
+// highlightColors are the colors of the highlighting tags in the light
+// scheme.
+var highlightColors = map[string]string{
+	"comment": "#008000",
+	"string":  "#a31515",
+	"keyword": "#0000ff",
+	"type":    "#2b91af",
+	"number":  "#098658",
+}
+
+type (
+	// highlighter colors the text of a text view by its language with text
+	// tags, shortly after it changes. The text is matched away from the main
+	// loop and only the parts which highlight differently are tagged again,
+	// so typing in a large file does not stall.
+	highlighter struct {
+		textView *textView
+		language *language
+		tags     map[string]*gtk.TextTag
+		pending  glib.SourceHandle
+
+		// spans are the tagged parts of the text, moved along as it is
+		// edited. Those an edit touched are dropped and the range from
+		// dirtyStart to dirtyEnd covers them and the edits, dirtyStart is -1
+		// when nothing changed.
+		spans                []highlightSpan
+		dirtyStart, dirtyEnd int
+
+		// generation counts the edits, matches of text which has changed
+		// since are thrown away.
+		generation int
+	}
+
+	// highlightSpan is a part of the text from the character offsets start to
+	// end which is colored with a tag.
+	highlightSpan struct {
+		start, end int
+		tag        string
+	}
+)
+
+func newHighlighter(t *textView) *highlighter {
+	h := &highlighter{
+		textView:   t,
+		tags:       make(map[string]*gtk.TextTag),
+		dirtyStart: -1,
+	}
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	scheme := t.doc.app.application.scheme
+
+	for name := range highlightColors {
+		h.tags[name] = buff.CreateTag("syntax-"+name, map[string]interface{}{
+			"foreground": scheme.syntaxColor(name),
+		})
+	}
+
+	// Both run before the buffer changes, at the offsets of the old text.
+	buff.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, text string) {
+		h.edit(iter.GetOffset(), 0, utf8.RuneCountInString(text))
+	})
+
+	buff.Connect("delete-range", func(_ *gtk.TextBuffer, start, end *gtk.TextIter) {
+		h.edit(start.GetOffset(), end.GetOffset()-start.GetOffset(), 0)
+	})
+
+	return h
+}
+
+// SetColors colors the highlighting with the syntax colors of s.
+func (h *highlighter) SetColors(s *colorScheme) {
+	for name, tag := range h.tags {
+		tag.SetProperty("foreground", s.syntaxColor(name))
+	}
+}
+
+// SetLanguage highlights the text as l, or leaves it plain when l is nil.
+func (h *highlighter) SetLanguage(l *language) {
+	h.language = l
+	h.Highlight()
+}
+
+// edit records that removed characters at offset were replaced by inserted
+// ones.
+func (h *highlighter) edit(offset, removed, inserted int) {
+	h.generation++
+	h.spans, h.dirtyStart, h.dirtyEnd = editSpans(h.spans, h.dirtyStart, h.dirtyEnd, offset, removed, inserted)
+	h.schedule()
+}
+
+func (h *highlighter) schedule() {
+	if h.pending != 0 || h.language == nil {
+		return
+	}
+
+	h.pending = glib.TimeoutAdd(highlightDelay, func() bool {
+		h.pending = 0
+		h.highlightChanges()
+
+		return false
+	})
+}
+
+// Highlight colors the whole text again.
+func (h *highlighter) Highlight() {
+	buff, _ := h.textView.GTKtextView.GetBuffer()
+	start, end := buff.GetStartIter(), buff.GetEndIter()
+
+	for _, tag := range h.tags {
+		buff.RemoveTag(tag, start, end)
+	}
+
+	h.generation++
+	h.spans = nil
+	h.dirtyStart = -1
+
+	l := h.language
+	if l == nil || !h.textView.doc.app.config.Highlight.Enable {
+		return
+	}
+
+	text := h.textView.Text()
+	if len(text) > highlightMaxSize {
+		return
+	}
+
+	h.apply(highlightSpans(l, text))
+}
+
+// highlightChanges matches the text in the background and then tags what
+// highlights differently since the last time.
+func (h *highlighter) highlightChanges() {
+	l := h.language
+	if h.dirtyStart < 0 || l == nil || !h.textView.doc.app.config.Highlight.Enable {
+		return
+	}
+
+	text := h.textView.Text()
+	if len(text) > highlightMaxSize {
+		h.Highlight()
+		return
+	}
+
+	generation := h.generation
+
+	go func() {
+		spans := highlightSpans(l, text)
+
+		glib.IdleAdd(func() {
+			// Text edited in the meantime is highlighted again once the
+			// edits pause.
+			if generation == h.generation {
+				h.apply(spans)
+			}
+		})
+	}()
+}
+
+// apply tags the text with spans, only changing the tags which differ from
+// the spans applied before.
+func (h *highlighter) apply(spans []highlightSpan) {
+	buff, _ := h.textView.GTKtextView.GetBuffer()
+	iter := buff.GetIterAtOffset
+
+	old, start, end := clearedSpans(h.spans, h.dirtyStart, h.dirtyEnd)
+
+	if start >= 0 {
+		for _, tag := range h.tags {
+			buff.RemoveTag(tag, iter(start), iter(end))
+		}
+	}
+
+	removed, added := diffSpans(old, spans)
+
+	for _, s := range removed {
+		buff.RemoveTag(h.tags[s.tag], iter(s.start), iter(s.end))
+	}
+
+	for _, s := range added {
+		buff.ApplyTag(h.tags[s.tag], iter(s.start), iter(s.end))
+	}
+
+	h.spans = spans
+	h.dirtyStart = -1
+}
+
+// highlightSpans returns the parts of text to color for the language l.
+func highlightSpans(l *language, text string) []highlightSpan {
+	var matches [][]int
+	var tags []string
+
+	for _, m := range l.pattern.FindAllStringSubmatchIndex(text, -1) {
+		for i, group := range l.groups {
+			if m[2*group] < 0 {
+				continue
+			}
+
+			if l.rules[i].group {
+				group++
+			}
+
+			if m[2*group] < m[2*group+1] {
+				matches = append(matches, m[2*group:2*group+2])
+				tags = append(tags, l.rules[i].tag)
+			}
+
+			break
+		}
+	}
+
+	spans := make([]highlightSpan, len(matches))
+
+	for i, offsets := range matchCharOffsets(text, matches) {
+		spans[i] = highlightSpan{start: offsets[0], end: offsets[1], tag: tags[i]}
+	}
+
+	return spans
+}
+
+// editSpans moves spans to where their text is after removed characters at
+// offset were replaced by inserted ones. The spans the edit touches are
+// dropped, as their tags may now cover part of the new text, and the dirty
+// range from start to end grows to cover them and the edit. start is -1 when
+// nothing was dirty.
+func editSpans(spans []highlightSpan, start, end, offset, removed, inserted int) ([]highlightSpan, int, int) {
+	delta := inserted - removed
+	move := func(i int) int {
+		switch {
+		case i >= offset+removed:
+			return i + delta
+		case i > offset:
+			return offset
+		}
+
+		return i
+	}
+
+	if start < 0 {
+		start, end = offset, offset+inserted
+	} else {
+		start, end = move(start), move(end)
+
+		if offset < start {
+			start = offset
+		}
+
+		if offset+inserted > end {
+			end = offset + inserted
+		}
+	}
+
+	kept := spans[:0]
+
+	for _, s := range spans {
+		switch {
+		case s.end < offset:
+			kept = append(kept, s)
+		case s.start > offset+removed:
+			s.start += delta
+			s.end += delta
+			kept = append(kept, s)
+		default:
+			if s.start < start {
+				start = s.start
+			}
+
+			if e := move(s.end); e > end {
+				end = e
+			}
+		}
+	}
+
+	return kept, start, end
+}
+
+// clearedSpans returns the spans outside of the dirty range from start to
+// end, which grows to cover the spans partly inside it so clearing the range
+// clears them completely. start is -1 when nothing is dirty.
+func clearedSpans(spans []highlightSpan, start, end int) ([]highlightSpan, int, int) {
+	if start < 0 {
+		return spans, start, end
+	}
+
+	var kept []highlightSpan
+
+	for _, s := range spans {
+		if s.start >= end || s.end <= start {
+			kept = append(kept, s)
+			continue
+		}
+
+		if s.start < start {
+			start = s.start
+		}
+
+		if s.end > end {
+			end = s.end
+		}
+	}
+
+	return kept, start, end
+}
+
+// diffSpans returns the spans of from which are not in to and those of to
+// which are not in from. Both are in the order of the text.
+func diffSpans(from, to []highlightSpan) (removed, added []highlightSpan) {
+	i, j := 0, 0
+
+	for i < len(from) && j < len(to) {
+		switch a, b := from[i], to[j]; {
+		case a == b:
+			i++
+			j++
+		case a.start < b.start || (a.start == b.start && a.end < b.end):
+			removed = append(removed, a)
+			i++
+		default:
+			added = append(added, b)
+			j++
+		}
+	}
+
+	removed = append(removed, from[i:]...)
+	added = append(added, to[j:]...)
+
+	return removed, added
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tagBuffer holds the tags of every character of a text, like a GTK buffer
+// in which a character can have several tags.
+type tagBuffer [][]string
+
+func (b tagBuffer) apply(s highlightSpan) {
+	for i := s.start; i < s.end; i++ {
+		if !b.has(i, s.tag) {
+			b[i] = append(b[i], s.tag)
+		}
+	}
+}
+
+func (b tagBuffer) remove(s highlightSpan) {
+	for i := s.start; i < s.end; i++ {
+		var kept []string
+		for _, tag := range b[i] {
+			if tag != s.tag {
+				kept = append(kept, tag)
+			}
+		}
+		b[i] = kept
+	}
+}
+
+func (b tagBuffer) has(i int, tag string) bool {
+	for _, t := range b[i] {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b tagBuffer) String() string {
+	parts := make([]string, len(b))
+	for i, tags := range b {
+		parts[i] = strings.Join(tags, "+")
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func renderSpans(n int, spans []highlightSpan) tagBuffer {
+	b := make(tagBuffer, n)
+	for _, s := range spans {
+		b.apply(s)
+	}
+
+	return b
+}
+
+func TestHighlightSpans(t *testing.T) {
+	got := highlightSpans(languageByID("go"), "x := \"é\" // 1\nreturn 10")
+	want := []highlightSpan{
+		{5, 8, "string"},
+		{9, 13, "comment"},
+		{14, 20, "keyword"},
+		{21, 23, "number"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("highlightSpans() = %v, want %v", got, want)
+	}
+}
+
+// TestEditSpans edits text the way the buffer does, where text inserted in a
+// tagged part takes the tag, and checks that tagging only what apply changes
+// ends up the same as highlighting the whole text again.
+func TestEditSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		text     string
+		edits    []struct {
+			offset, removed int
+			inserted        string
+		}
+	}{
+		{"type in a word", "go", "func main() {\n\treturn 1\n}\n", []struct {
+			offset, removed int
+			inserted        string
+		}{{16, 0, "x"}}},
+		{"open a block comment", "go", "a := 1\nb := 2\nreturn a // done */\n", []struct {
+			offset, removed int
+			inserted        string
+		}{{0, 0, "/*"}}},
+		{"close a string", "go", "x := \"abc\nif true {}\n", []struct {
+			offset, removed int
+			inserted        string
+		}{{9, 0, "\""}}},
+		{"delete across spans", "python", "def f():\n    return \"x\" # c\nclass A: pass\n", []struct {
+			offset, removed int
+			inserted        string
+		}{{4, 20, ""}}},
+		{"several edits", "yaml", "key: value\nlist:\n  - 1\n  - two # c\n", []struct {
+			offset, removed int
+			inserted        string
+		}{{0, 3, "name"}, {20, 0, "\"q\""}, {2, 1, ""}}},
+	}
+
+	for _, tt := range tests {
+		l := languageByID(tt.language)
+		text := []rune(tt.text)
+		spans := highlightSpans(l, tt.text)
+		buffer := renderSpans(len(text), spans)
+		start, end := -1, -1
+
+		for _, e := range tt.edits {
+			inserted := []rune(e.inserted)
+
+			var tags []string
+			if e.offset > 0 && e.offset < len(buffer) {
+				for _, tag := range buffer[e.offset-1] {
+					if buffer.has(e.offset, tag) {
+						tags = append(tags, tag)
+					}
+				}
+			}
+
+			added := make(tagBuffer, len(inserted))
+			for i := range added {
+				added[i] = append([]string(nil), tags...)
+			}
+
+			text = append(append(append([]rune{}, text[:e.offset]...), inserted...), text[e.offset+e.removed:]...)
+			buffer = append(append(append(tagBuffer{}, buffer[:e.offset]...), added...), buffer[e.offset+e.removed:]...)
+			spans, start, end = editSpans(spans, start, end, e.offset, e.removed, len(inserted))
+		}
+
+		want := highlightSpans(l, string(text))
+
+		old, start, end := clearedSpans(spans, start, end)
+		for i := start; i >= 0 && i < end; i++ {
+			buffer[i] = nil
+		}
+
+		removed, added := diffSpans(old, want)
+		for _, s := range removed {
+			buffer.remove(s)
+		}
+
+		for _, s := range added {
+			buffer.apply(s)
+		}
+
+		if got := renderSpans(len(text), want); buffer.String() != got.String() {
+			t.Errorf("%s: tagged\n%s\nwant\n%s", tt.name, buffer, got)
+		}
+	}
+}
+
+func TestDiffSpans(t *testing.T) {
+	from := []highlightSpan{{0, 2, "a"}, {3, 5, "b"}, {6, 8, "c"}}
+	to := []highlightSpan{{0, 2, "a"}, {3, 5, "c"}, {7, 9, "c"}}
+
+	removed, added := diffSpans(from, to)
+
+	if want := []highlightSpan{{3, 5, "b"}, {6, 8, "c"}}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+
+	if want := []highlightSpan{{3, 5, "c"}, {7, 9, "c"}}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+type (
+	// instanceRequest is sent by an editor started while another one is
+	// running, asking it to open the files in its window instead or to carry
+	// out one of the --remote commands.
+	instanceRequest struct {
+		Command  string
+		Files    []fileArg
+		Stdin    bool
+		Input    []byte
+		Create   bool
+		ReadOnly bool
+		Encoding string
+		Wait     bool
+
+		// Line and Column of goto, one based.
+		Line   int
+		Column int
+
+		// Text of insert.
+		Text string
+	}
+
+	// instanceResponse answers an instanceRequest. With Wait it is only sent
+	// once the opened documents are closed.
+	instanceResponse struct {
+		Error string
+	}
+
+	// instanceServer accepts the requests of other editors on a unix socket.
+	instanceServer struct {
+		app      *application
+		listener net.Listener
+	}
+)
+
+var lineColumnArgRegexp = regexp.MustCompile(`^(\d+)(?::(\d+))?$`)
+
+// instanceSocket is the socket the running editor listens on.
+func instanceSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "go-notepad.sock")
+	}
+
+	return filepath.Join(stateDir(), "instance.sock")
+}
+
+// singleInstance reports whether the config asks for files to be opened in
+// the editor which is already running. Only such an editor serves the socket,
+// otherwise every editor is a process of its own.
+func singleInstance(configFilename string) bool {
+	c, err := readConfig(configFilename)
+
+	return err == nil && c.Instance.Single
+}
+
+func newInstanceServer(app *application) *instanceServer {
+	s := &instanceServer{app: app}
+	path := instanceSocket()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		fmt.Printf("failed creating %s: %s\n", filepath.Dir(path), err)
+		return s
+	}
+
+	l, err := net.Listen("unix", path)
+
+	if err != nil {
+		// Another editor is already serving, leave it be.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return s
+		}
+
+		// The socket was left behind by an editor which did not exit cleanly.
+		os.Remove(path)
+
+		if l, err = net.Listen("unix", path); err != nil {
+			fmt.Printf("failed listening on %s: %s\n", path, err)
+			return s
+		}
+	}
+
+	s.listener = l
+	go s.run()
+
+	return s
+}
+
+func (s *instanceServer) run() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *instanceServer) handle(conn net.Conn) {
+	var req instanceRequest
+
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		fmt.Printf("failed reading instance request: %s\n", err)
+		conn.Close()
+		return
+	}
+
+	respond := func(err error) {
+		var res instanceResponse
+		if err != nil {
+			res.Error = err.Error()
+		}
+
+		json.NewEncoder(conn).Encode(res)
+		conn.Close()
+	}
+
+	glib.IdleAdd(func() {
+		s.app.handleRequest(&req, respond)
+	})
+}
+
+// Close stops accepting requests and removes the socket.
+func (s *instanceServer) Close() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// handleRequest carries out req in the main loop and calls respond when it is
+// done.
+func (ap *application) handleRequest(req *instanceRequest, respond func(error)) {
+	a := ap.window()
+
+	switch req.Command {
+	case "open":
+		opts := req.options()
+		docs := a.Init(opts)
+		a.Win.Present()
+
+		// Starting another editor without files opens a new document.
+		if !opts.opensDocuments() {
+			a.SetCurrentDocument(a.blankDocument())
+		}
+
+		if !req.Wait {
+			respond(nil)
+			return
+		}
+
+		if len(docs) == 0 {
+			docs = append(docs, a.blankDocument())
+		}
+
+		waitForClose(docs, func() {
+			respond(nil)
+		})
+	case "goto":
+		a.doc.textView.GoToLineColumn(req.Line-1, req.Column-1)
+		a.Win.Present()
+		respond(nil)
+	case "insert":
+		if !a.doc.textView.InsertAtCursor(req.Text) {
+			respond(fmt.Errorf("%s is read only", a.doc.Name()))
+			return
+		}
+
+		respond(nil)
+	case "save":
+		docs := []*document{a.doc}
+
+		if len(req.Files) > 0 {
+			docs = nil
+
+			for _, f := range req.Files {
+				d := a.findDocument(f.Filename)
+				if d == nil {
+					respond(fmt.Errorf("%s is not open", f.Filename))
+					return
+				}
+
+				docs = append(docs, d)
+			}
+		}
+
+		for _, d := range docs {
+			if !d.Save() {
+				respond(fmt.Errorf("%s was not saved", d.openedFilename))
+				return
+			}
+		}
+
+		respond(nil)
+	case "quit":
+		respond(nil)
+		ap.Quit()
+	default:
+		respond(fmt.Errorf("unknown command %q", req.Command))
+	}
+}
+
+// waitForClose calls done once every document in docs is closed.
+func waitForClose(docs []*document, done func()) {
+	left := len(docs)
+
+	for _, d := range docs {
+		d.onClose(func() {
+			if left--; left == 0 {
+				done()
+			}
+		})
+	}
+}
+
+// options turns the request back into the command line of the editor which
+// sent it.
+func (req *instanceRequest) options() *cliOptions {
+	return &cliOptions{
+		files:    req.Files,
+		stdin:    req.Stdin,
+		input:    req.Input,
+		create:   req.Create,
+		readOnly: req.ReadOnly,
+		wait:     req.Wait,
+		encoding: encodingByName(req.Encoding),
+	}
+}
+
+// request turns the command line into a request for the running editor.
+// File names are made absolute as its working directory may differ.
+func (opts *cliOptions) request() *instanceRequest {
+	req := &instanceRequest{
+		Command:  "open",
+		Stdin:    opts.stdin,
+		Input:    opts.input,
+		Create:   opts.create,
+		ReadOnly: opts.readOnly,
+		Wait:     opts.wait,
+	}
+
+	for _, f := range opts.files {
+		f.Filename = absPath(f.Filename)
+		req.Files = append(req.Files, f)
+	}
+
+	if opts.encoding != nil {
+		req.Encoding = opts.encoding.Name
+	}
+
+	return req
+}
+
+// remoteRequest turns the --remote command and its arguments into a request
+// for the running editor.
+func (opts *cliOptions) remoteRequest() (*instanceRequest, error) {
+	req := &instanceRequest{Command: opts.remote}
+
+	switch opts.remote {
+	case "open":
+		req = opts.request()
+	case "goto":
+		var m []string
+		if len(opts.args) == 1 {
+			m = lineColumnArgRegexp.FindStringSubmatch(opts.args[0])
+		}
+
+		if m == nil {
+			return nil, errors.New("goto takes a line[:column]")
+		}
+
+		req.Line, _ = strconv.Atoi(m[1])
+		req.Column, _ = strconv.Atoi(m[2])
+	case "insert":
+		if opts.stdin {
+			req.Text = string(opts.input)
+		} else {
+			req.Text = strings.Join(opts.args, " ")
+		}
+	case "save":
+		for _, f := range opts.files {
+			req.Files = append(req.Files, fileArg{Filename: absPath(f.Filename)})
+		}
+	case "quit":
+		if len(opts.args) > 0 {
+			return nil, errors.New("quit takes no arguments")
+		}
+	default:
+		return nil, fmt.Errorf("unknown command %q, use one of: open, goto, insert, save, quit", opts.remote)
+	}
+
+	return req, nil
+}
+
+// sendToInstance hands req to the running editor and waits for its response.
+// It reports false when no editor is running.
+func sendToInstance(req *instanceRequest) (bool, error) {
+	conn, err := net.Dial("unix", instanceSocket())
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return true, err
+	}
+
+	var res instanceResponse
+
+	// The editor exiting closes the documents too.
+	if err := json.NewDecoder(conn).Decode(&res); errors.Is(err, io.EOF) {
+		return true, nil
+	} else if err != nil {
+		return true, err
+	}
+
+	if res.Error != "" {
+		return true, errors.New(res.Error)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type (
+	// language is a kind of text which is highlighted, such as Go or YAML.
+	language struct {
+		ID   string
+		Name string
+
+		// aliases are the other names used for the language in modelines.
+		aliases      []string
+		extensions   []string
+		filenames    []string
+		interpreters []string
+		rules        []highlightRule
+
+		// pattern matches any of the rules, groups holds the group of each
+		// rule in it, see compile.
+		pattern *regexp.Regexp
+		groups  []int
+	}
+
+	// highlightRule shows what pattern matches with the tag, or only the
+	// first group of pattern when group is set.
+	highlightRule struct {
+		tag     string
+		pattern string
+		group   bool
+	}
+)
+
+// plainTextID is the language action state of documents without highlighting.
+const plainTextID = "plain"
+
+const (
+	numberPattern      = `\b(?:0[xX][0-9a-fA-F_]+|[0-9][0-9_]*(?:\.[0-9_]*)?(?:[eE][+-]?[0-9]+)?)\b`
+	doubleQuotePattern = `"(?:[^"\\\n]|\\.)*"`
+	singleQuotePattern = `'(?:[^'\\\n]|\\.)*'`
+	hashCommentPattern = `(?m:(?:^|[ \t])#[^\n]*)`
+)
+
+// languages are the languages which can be highlighted, in the order of the
+// View > Language menu.
+var languages = []*language{
+	{
+		ID:         "go",
+		Name:       "Go",
+		aliases:    []string{"golang"},
+		extensions: []string{".go"},
+		rules: []highlightRule{
+			{tag: "comment", pattern: `//[^\n]*|/\*(?s:.*?)\*/`},
+			{tag: "string", pattern: doubleQuotePattern + "|`[^`]*`|" + singleQuotePattern},
+			{tag: "keyword", pattern: words("break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select", "struct", "switch", "type", "var")},
+			{tag: "type", pattern: words("any", "bool", "byte", "complex64", "complex128", "error", "false", "float32", "float64", "int", "int8", "int16", "int32", "int64", "iota", "nil", "rune", "string", "true", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr")},
+			{tag: "number", pattern: numberPattern},
+		},
+	},
+	{
+		ID:           "sh",
+		Name:         "Shell",
+		aliases:      []string{"bash", "shell", "zsh", "ksh"},
+		extensions:   []string{".sh", ".bash", ".zsh", ".ksh"},
+		filenames:    []string{".bashrc", ".bash_profile", ".profile", ".zshrc", "PKGBUILD"},
+		interpreters: []string{"sh", "bash", "zsh", "ksh", "dash"},
+		rules: []highlightRule{
+			{tag: "comment", pattern: hashCommentPattern},
+			{tag: "string", pattern: `"(?:[^"\\]|\\.)*"|'[^']*'`},
+			{tag: "keyword", pattern: words("break", "case", "continue", "do", "done", "elif", "else", "esac", "exit", "export", "fi", "for", "function", "if", "in", "local", "return", "then", "until", "while")},
+			{tag: "type", pattern: `\$(?:\{[^}\n]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9#?@*$!-])`},
+			{tag: "number", pattern: numberPattern},
+		},
+	},
+	{
+		ID:           "python",
+		Name:         "Python",
+		aliases:      []string{"py", "python3"},
+		extensions:   []string{".py", ".pyw"},
+		interpreters: []string{"python"},
+		rules: []highlightRule{
+			{tag: "comment", pattern: `#[^\n]*`},
+			{tag: "string", pattern: `"""(?s:.*?)"""|'''(?s:.*?)'''|` + doubleQuotePattern + `|` + singleQuotePattern},
+			{tag: "keyword", pattern: words("and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield")},
+			{tag: "type", pattern: words("False", "None", "True", "self")},
+			{tag: "number", pattern: numberPattern},
+		},
+	},
+	{
+		ID:         "yaml",
+		Name:       "YAML",
+		aliases:    []string{"yml"},
+		extensions: []string{".yml", ".yaml"},
+		rules: []highlightRule{
+			{tag: "keyword", pattern: `(?m:^[ \t]*(?:-[ \t]+)?([^\s#'"-][^:#\n]*?)[ \t]*:(?:[ \t]|$))`, group: true},
+			{tag: "comment", pattern: hashCommentPattern},
+			{tag: "string", pattern: doubleQuotePattern + `|'[^'\n]*'`},
+			{tag: "type", pattern: words("true", "false", "null", "yes", "no", "on", "off") + `|~`},
+			{tag: "number", pattern: numberPattern},
+		},
+	},
+	{
+		ID:         "json",
+		Name:       "JSON",
+		extensions: []string{".json"},
+		rules: []highlightRule{
+			{tag: "keyword", pattern: `(` + doubleQuotePattern + `)[ \t]*:`, group: true},
+			{tag: "string", pattern: doubleQuotePattern},
+			{tag: "type", pattern: words("true", "false", "null")},
+			{tag: "number", pattern: `-?` + numberPattern},
+		},
+	},
+	{
+		ID:         "markdown",
+		Name:       "Markdown",
+		aliases:    []string{"md"},
+		extensions: []string{".md", ".markdown"},
+		rules: []highlightRule{
+			{tag: "string", pattern: "```(?s:.*?)```|`[^`\\n]+`"},
+			{tag: "keyword", pattern: `(?m:^#{1,6}[ \t][^\n]*)`},
+			{tag: "comment", pattern: `(?m:^>[^\n]*)`},
+			{tag: "type", pattern: `\*\*[^*\n]+\*\*|\*[^*\s][^*\n]*\*|\[[^\]\n]*\]\([^)\n]*\)`},
+		},
+	},
+}
+
+var (
+	shebangRegexp     = regexp.MustCompile(`^#![ \t]*(\S+)(?:[ \t]+(\S+))?`)
+	vimModelineRegexp = regexp.MustCompile(`\b(?:vim?|ex):.*\b(?:ft|filetype|syntax)=([\w+-]+)`)
+	emacsModeRegexp   = regexp.MustCompile(`-\*-[ \t]*(?:[^\n]*?\bmode:[ \t]*([\w+-]+)|([\w+-]+)[ \t]*-\*-)`)
+)
+
+// modelineLines is how many lines at the start and end of the text are
+// searched for a modeline.
+const modelineLines = 5
+
+func init() {
+	for _, l := range languages {
+		l.compile()
+	}
+}
+
+// words returns a pattern matching any of the whole words.
+func words(w ...string) string {
+	return `\b(?:` + strings.Join(w, "|") + `)\b`
+}
+
+// compile joins the rules into one pattern so the earliest match in the text
+// wins, and a comment inside a string is not a comment.
+func (l *language) compile() {
+	parts := make([]string, len(l.rules))
+	l.groups = make([]int, len(l.rules))
+	group := 1
+
+	for i, r := range l.rules {
+		parts[i] = "(" + r.pattern + ")"
+		l.groups[i] = group
+		group += 1 + regexp.MustCompile(r.pattern).NumSubexp()
+	}
+
+	l.pattern = regexp.MustCompile(strings.Join(parts, "|"))
+}
+
+// languageByID returns the language with the ID, or nil for plain text.
+func languageByID(id string) *language {
+	for _, l := range languages {
+		if l.ID == id {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// languageByName finds the language named in a modeline, such as "golang"
+// or "bash".
+func languageByName(name string) *language {
+	name = strings.ToLower(name)
+
+	for _, l := range languages {
+		if l.ID == name || strings.ToLower(l.Name) == name {
+			return l
+		}
+
+		for _, alias := range l.aliases {
+			if alias == name {
+				return l
+			}
+		}
+	}
+
+	return nil
+}
+
+// detectLanguage guesses the language of text from a modeline, the name of
+// filename or the interpreter of a shebang line, in that order. It returns
+// nil for plain text.
+func detectLanguage(filename, text string) *language {
+	if l := modelineLanguage(text); l != nil {
+		return l
+	}
+
+	if filename != "" {
+		base := filepath.Base(filename)
+		ext := strings.ToLower(filepath.Ext(base))
+
+		for _, l := range languages {
+			for _, name := range l.filenames {
+				if name == base {
+					return l
+				}
+			}
+
+			for _, e := range l.extensions {
+				if e == ext {
+					return l
+				}
+			}
+		}
+	}
+
+	return shebangLanguage(text)
+}
+
+// modelineLanguage returns the language set by a vim or emacs modeline in the
+// first or last lines of text.
+func modelineLanguage(text string) *language {
+	lines := strings.SplitN(text, "\n", modelineLines+1)
+	if len(lines) > modelineLines {
+		lines = lines[:modelineLines]
+	}
+
+	end := strings.Split(text[len(text)-tailLength(text, modelineLines):], "\n")
+	lines = append(lines, end...)
+
+	for _, line := range lines {
+		if m := vimModelineRegexp.FindStringSubmatch(line); m != nil {
+			if l := languageByName(m[1]); l != nil {
+				return l
+			}
+		}
+
+		if m := emacsModeRegexp.FindStringSubmatch(line); m != nil {
+			name := m[1]
+			if name == "" {
+				name = m[2]
+			}
+
+			if l := languageByName(name); l != nil {
+				return l
+			}
+		}
+	}
+
+	return nil
+}
+
+// tailLength returns the length in bytes of the last n lines of text.
+func tailLength(text string, n int) int {
+	i := len(text)
+
+	for ; n > 0 && i > 0; n-- {
+		i = strings.LastIndexByte(strings.TrimRight(text[:i], "\n"), '\n') + 1
+	}
+
+	return len(text) - i
+}
+
+// shebangLanguage returns the language of the interpreter named on the
+// shebang line of a script, such as "#!/usr/bin/env python3".
+func shebangLanguage(text string) *language {
+	m := shebangRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+
+	interpreter := filepath.Base(m[1])
+	if interpreter == "env" {
+		interpreter = filepath.Base(m[2])
+	}
+
+	// python3.11 is python.
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+
+	for _, l := range languages {
+		for _, name := range l.interpreters {
+			if name == interpreter {
+				return l
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		text     string
+		want     string
+	}{
+		{"extension", "main.go", "package main\n", "go"},
+		{"upper case extension", "README.MD", "# Title\n", "markdown"},
+		{"file name", "/home/user/.bashrc", "alias ll='ls -l'\n", "sh"},
+		{"shebang", "deploy", "#!/bin/bash\necho hi\n", "sh"},
+		{"env shebang", "tool", "#!/usr/bin/env python3\nprint(1)\n", "python"},
+		{"modeline wins over extension", "notes.txt.go", "// vim: set ft=yaml:\n", "yaml"},
+		{"plain text", "notes.txt", "hello\n", ""},
+		{"untitled", "", "{\"a\": 1}\n", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if l := detectLanguage(tt.filename, tt.text); l != nil {
+			got = l.ID
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: detectLanguage(%q) = %q, want %q", tt.name, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestModelineLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"vim first line", "# vim: ft=python\nx = 1\n", "python"},
+		{"vim filetype", "x\n/* vi: set filetype=golang: */\n", "go"},
+		{"vim syntax", "ex: syntax=sh\n", "sh"},
+		{"vim last line", "a\nb\nc\nd\ne\nf\ng\n# vim: ft=yaml\n", "yaml"},
+		{"vim middle line", "a\nb\nc\nd\ne\n# vim: ft=yaml\nf\ng\nh\ni\nj\nk\n", ""},
+		{"emacs mode", "# -*- mode: markdown -*-\n", "markdown"},
+		{"emacs short", "# -*- json -*-\n", "json"},
+		{"unknown language", "# vim: ft=cobol\n", ""},
+		{"none", "hello\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if l := modelineLanguage(tt.text); l != nil {
+			got = l.ID
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: modelineLanguage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShebangLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"#!/bin/sh\n", "sh"},
+		{"#! /usr/bin/zsh -f\n", "sh"},
+		{"#!/usr/bin/env bash\n", "sh"},
+		{"#!/usr/bin/python3.11\n", "python"},
+		{"#!/usr/bin/env python3\n", "python"},
+		{"#!/usr/bin/perl\n", ""},
+		{"echo #!/bin/sh\n", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if l := shebangLanguage(tt.text); l != nil {
+			got = l.ID
+		}
+
+		if got != tt.want {
+			t.Errorf("shebangLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTailLength(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want int
+	}{
+		{"a\nb\nc\n", 1, 2},
+		{"a\nb\nc\n", 2, 4},
+		{"a\nb\nc", 1, 1},
+		{"a\nb\nc", 5, 5},
+		{"abc", 1, 3},
+		{"a\n\n\n", 1, 4},
+		{"", 3, 0},
+		{"a\nb\n", 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tailLength(tt.text, tt.n); got != tt.want {
+			t.Errorf("tailLength(%q, %d) = %d, want %d", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
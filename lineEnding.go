@@ -0,0 +1,120 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+)
+
+type lineEnding int
+
+const (
+	lineEndingLF lineEnding = iota
+	lineEndingCRLF
+	lineEndingCR
+)
+
+var lineEndings = []lineEnding{lineEndingCRLF, lineEndingLF, lineEndingCR}
+
+// defaultLineEnding is used for new documents and matches the platform.
+func defaultLineEnding() lineEnding {
+	if runtime.GOOS == "windows" {
+		return lineEndingCRLF
+	}
+
+	return lineEndingLF
+}
+
+func (l lineEnding) String() string {
+	switch l {
+	case lineEndingCRLF:
+		return "Windows (CRLF)"
+	case lineEndingCR:
+		return "Macintosh (CR)"
+	default:
+		return "Unix (LF)"
+	}
+}
+
+// name identifies the line ending in the win.line-ending action.
+func (l lineEnding) name() string {
+	switch l {
+	case lineEndingCRLF:
+		return "crlf"
+	case lineEndingCR:
+		return "cr"
+	default:
+		return "lf"
+	}
+}
+
+func lineEndingByName(name string) (lineEnding, bool) {
+	for _, l := range lineEndings {
+		if l.name() == name {
+			return l, true
+		}
+	}
+
+	return lineEndingLF, false
+}
+
+func (l lineEnding) sequence() string {
+	switch l {
+	case lineEndingCRLF:
+		return "\r\n"
+	case lineEndingCR:
+		return "\r"
+	default:
+		return "\n"
+	}
+}
+
+// detectLineEnding returns the most common line ending of text and whether
+// more than one kind is used. Text without any line breaks gets the default.
+func detectLineEnding(text string) (ending lineEnding, mixed bool) {
+	var counts [3]int
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			counts[lineEndingLF]++
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				counts[lineEndingCRLF]++
+				i++
+			} else {
+				counts[lineEndingCR]++
+			}
+		}
+	}
+
+	ending = defaultLineEnding()
+	kinds := 0
+
+	for _, l := range lineEndings {
+		if counts[l] > 0 {
+			kinds++
+		}
+
+		if counts[l] > counts[ending] {
+			ending = l
+		}
+	}
+
+	return ending, kinds > 1
+}
+
+// normalizeLineEndings converts every line ending of text to the "\n" the
+// GTK buffer works with.
+func normalizeLineEndings(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+// apply converts the "\n" line endings of buffer text to l.
+func (l lineEnding) apply(text string) string {
+	if l == lineEndingLF {
+		return text
+	}
+
+	return strings.ReplaceAll(text, "\n", l.sequence())
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		ending lineEnding
+		mixed  bool
+	}{
+		{"lf", "one\ntwo\n", lineEndingLF, false},
+		{"crlf", "one\r\ntwo\r\n", lineEndingCRLF, false},
+		{"cr", "one\rtwo\r", lineEndingCR, false},
+		{"mixed", "one\r\ntwo\r\nthree\n", lineEndingCRLF, true},
+		{"mixed tie", "one\ntwo\r", lineEndingLF, true},
+		{"no newline", "one", defaultLineEnding(), false},
+		{"empty", "", defaultLineEnding(), false},
+		{"trailing lone cr", "one\r\ntwo\r\nthree\r", lineEndingCRLF, true},
+		{"only a cr", "\r", lineEndingCR, false},
+	}
+
+	for _, tt := range tests {
+		ending, mixed := detectLineEnding(tt.text)
+		if ending != tt.ending || mixed != tt.mixed {
+			t.Errorf("%s: detectLineEnding() = %s, %t, want %s, %t", tt.name, ending, mixed, tt.ending, tt.mixed)
+		}
+	}
+}
@@ -2,35 +2,19 @@ package main
 
 import (
 	"log"
-	"strconv"
 
 	"github.com/gotk3/gotk3/gtk"
 )
 
 type (
+	// menu is the menu bar of a window. Its items activate the window and
+	// application actions, which keep them checked and sensitive.
 	menu struct {
 		app        *app
 		gtkmenuBar *gtk.MenuBar
 
-		newMenuItem    *gtk.MenuItem
-		openMenuItem   *gtk.MenuItem
-		saveMenuItem   *gtk.MenuItem
-		saveAsMenuItem *gtk.MenuItem
-		exitMenuItem   *gtk.MenuItem
-
-		undoMenuItem     *gtk.MenuItem
-		cutMenuItem      *gtk.MenuItem
-		copyMenuItem     *gtk.MenuItem
-		pasteMenuItem    *gtk.MenuItem
-		deleteMenuItem   *gtk.MenuItem
-		timedateMenuItem *gtk.MenuItem
-
-		wordWrapMenuItem  *gtk.CheckMenuItem
-		statusBarMenuItem *gtk.CheckMenuItem
-
-		fontMenuItem *gtk.MenuItem
-
-		aboutMenuItem *gtk.MenuItem
+		recentMenuItem *gtk.MenuItem
+		recentMenu     *gtk.Menu
 	}
 )
 
@@ -43,15 +27,6 @@ func newMenu(app *app) *menu {
 		log.Fatal("unable to create menubar:", err)
 	}
 
-	// Define accel group to define our hotkeys.
-	app.accelGroup, err = gtk.AccelGroupNew()
-
-	if err != nil {
-		log.Fatal("unable to create accelGroup:", err)
-	}
-
-	app.Win.AddAccelGroup(app.accelGroup)
-
 	m := &menu{
 		app:        app,
 		gtkmenuBar: menubar,
@@ -69,44 +44,56 @@ func newMenu(app *app) *menu {
 	return m
 }
 
-func (m *menu) setupFileMenu() {
-	fileMenu, _ := gtk.MenuNew()
-	fileMain, _ := gtk.MenuItemNewWithLabel("File")
+// setAction makes mi activate the detailed action name, such as "win.save",
+// and shows its keyboard shortcut.
+func setAction(mi *gtk.MenuItem, name string) {
+	(&gtk.Actionable{Object: mi.Object}).SetDetailedActionName(name)
 
-	m.newMenuItem, _ = gtk.MenuItemNewWithLabel("New")
-	key, mod := gtk.AcceleratorParse("<Control>N")
-	m.newMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
+	if accels := actionAccels[name]; len(accels) > 0 {
+		showAccel(mi, accels[0])
+	}
+}
 
-	m.openMenuItem, _ = gtk.MenuItemNewWithLabel("Open...")
-	key, mod = gtk.AcceleratorParse("<Control>O")
-	m.openMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
+func newActionMenuItem(label, action string) *gtk.MenuItem {
+	mi, _ := gtk.MenuItemNewWithLabel(label)
+	setAction(mi, action)
 
-	m.saveMenuItem, _ = gtk.MenuItemNewWithLabel("Save")
-	key, mod = gtk.AcceleratorParse("<Control>S")
-	m.saveMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
+	return mi
+}
 
-	m.saveAsMenuItem, _ = gtk.MenuItemNewWithLabel("Save As...")
+func newActionCheckMenuItem(label, action string) *gtk.CheckMenuItem {
+	mi, _ := gtk.CheckMenuItemNewWithLabel(label)
+	setAction(&mi.MenuItem, action)
 
-	pageSetupMi, _ := gtk.MenuItemNewWithLabel("Page Setup...")
-	printMi, _ := gtk.MenuItemNewWithLabel("Print...")
-	key, mod = gtk.AcceleratorParse("<Control>P")
-	printMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
+	return mi
+}
 
-	m.exitMenuItem, _ = gtk.MenuItemNewWithLabel("Exit")
+func (m *menu) setupFileMenu() {
+	fileMenu, _ := gtk.MenuNew()
+	fileMain, _ := gtk.MenuItemNewWithLabel("File")
+
+	m.recentMenuItem, _ = gtk.MenuItemNewWithLabel("Recent Files")
+	m.recentMenu, _ = gtk.MenuNew()
+	m.recentMenuItem.SetSubmenu(m.recentMenu)
 
 	sepMi1, _ := gtk.SeparatorMenuItemNew()
 	sepMi2, _ := gtk.SeparatorMenuItemNew()
 
 	fileMain.SetSubmenu(fileMenu)
-	fileMenu.Append(m.newMenuItem)
-	fileMenu.Append(m.openMenuItem)
-	fileMenu.Append(m.saveMenuItem)
-	fileMenu.Append(m.saveAsMenuItem)
+	fileMenu.Append(newActionMenuItem("New", "win.new"))
+	fileMenu.Append(newActionMenuItem("New Window", "app.new-window"))
+	fileMenu.Append(newActionMenuItem("Open...", "app.open"))
+	fileMenu.Append(m.recentMenuItem)
+	fileMenu.Append(newActionMenuItem("Save", "win.save"))
+	fileMenu.Append(newActionMenuItem("Save As...", "win.save-as"))
+	fileMenu.Append(newActionMenuItem("Close Tab", "win.close-tab"))
 	fileMenu.Append(sepMi1)
-	fileMenu.Append(pageSetupMi)
-	fileMenu.Append(printMi)
+	fileMenu.Append(newActionMenuItem("Page Setup...", "win.page-setup"))
+	fileMenu.Append(newActionMenuItem("Print Preview...", "win.print-preview"))
+	fileMenu.Append(newActionMenuItem("Print...", "win.print"))
+	fileMenu.Append(newActionMenuItem("Export to PDF...", "win.export-pdf"))
 	fileMenu.Append(sepMi2)
-	fileMenu.Append(m.exitMenuItem)
+	fileMenu.Append(newActionMenuItem("Exit", "app.quit"))
 
 	m.gtkmenuBar.Append(fileMain)
 }
@@ -115,112 +102,48 @@ func (m *menu) setupEditMenu() {
 	editMenu, _ := gtk.MenuNew()
 	editMain, _ := gtk.MenuItemNewWithLabel("Edit")
 
-	m.undoMenuItem, _ = gtk.MenuItemNewWithLabel("Undo")
-	key, mod := gtk.AcceleratorParse("<Control>Z")
-	m.undoMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
 	sepMi1, _ := gtk.SeparatorMenuItemNew()
-	m.cutMenuItem, _ = gtk.MenuItemNewWithLabel("Cut")
-	key, mod = gtk.AcceleratorParse("<Control>X")
-	m.cutMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-	m.cutMenuItem.Connect("activate", func() {
-		m.app.textView.Cut()
-	})
-
-	m.copyMenuItem, _ = gtk.MenuItemNewWithLabel("Copy")
-	key, mod = gtk.AcceleratorParse("<Control>C")
-	m.copyMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-	m.copyMenuItem.Connect("activate", func() {
-		m.app.textView.Copy()
-	})
-
-	m.pasteMenuItem, _ = gtk.MenuItemNewWithLabel("Paste")
-	key, mod = gtk.AcceleratorParse("<Control>V")
-	m.pasteMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-	m.pasteMenuItem.Connect("activate", func() {
-		m.app.textView.Paste()
-	})
-
-	m.deleteMenuItem, _ = gtk.MenuItemNewWithLabel("Delete")
-	m.deleteMenuItem.Connect("activate", func() {
-		m.app.textView.Backspace()
-	})
-
 	sepMi2, _ := gtk.SeparatorMenuItemNew()
-
-	findMi, _ := gtk.MenuItemNewWithLabel("Find...")
-	key, mod = gtk.AcceleratorParse("<Control>F")
-	findMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
-	findNextMi, _ := gtk.MenuItemNewWithLabel("Find Next")
-	key, mod = gtk.AcceleratorParse("F3")
-	findNextMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
-	replaceMi, _ := gtk.MenuItemNewWithLabel("Replace...")
-	key, mod = gtk.AcceleratorParse("<Control>H")
-	replaceMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
-	goToMi, _ := gtk.MenuItemNewWithLabel("Go To...")
-	key, mod = gtk.AcceleratorParse("<Control>G")
-	goToMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-	goToMi.Connect("activate", func() {
-		response, line := displayGotoLine(m.app)
-
-		if response == gtk.RESPONSE_OK {
-			i, _ := strconv.ParseInt(line, 10, 64)
-			m.app.textView.GoToLine(int(i - 1))
-		}
-	})
-
 	sepMi3, _ := gtk.SeparatorMenuItemNew()
 
-	selectAllMi, _ := gtk.MenuItemNewWithLabel("Select All")
-	key, mod = gtk.AcceleratorParse("<Control>A")
-	selectAllMi.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-	selectAllMi.Connect("activate", func() {
-		m.app.textView.SelectAll()
-	})
-
-	m.timedateMenuItem, _ = gtk.MenuItemNewWithLabel("Time/Date")
-	key, mod = gtk.AcceleratorParse("F5")
-	m.timedateMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
 	editMain.SetSubmenu(editMenu)
-	editMenu.Append(m.undoMenuItem)
+	editMenu.Append(newActionMenuItem("Undo", "win.undo"))
+	editMenu.Append(newActionMenuItem("Redo", "win.redo"))
 	editMenu.Append(sepMi1)
-	editMenu.Append(m.cutMenuItem)
-	editMenu.Append(m.copyMenuItem)
-	editMenu.Append(m.pasteMenuItem)
-	editMenu.Append(m.deleteMenuItem)
+	editMenu.Append(newActionMenuItem("Cut", "win.cut"))
+	editMenu.Append(newActionMenuItem("Copy", "win.copy"))
+	editMenu.Append(newActionMenuItem("Paste", "win.paste"))
+	editMenu.Append(newActionMenuItem("Delete", "win.delete"))
 	editMenu.Append(sepMi2)
 
-	editMenu.Append(findMi)
-	editMenu.Append(findNextMi)
-	editMenu.Append(replaceMi)
-	editMenu.Append(goToMi)
+	editMenu.Append(newActionMenuItem("Find...", "win.find"))
+	editMenu.Append(newActionMenuItem("Find Next", "win.find-next"))
+	editMenu.Append(newActionMenuItem("Replace...", "win.replace"))
+	editMenu.Append(newActionMenuItem("Go To...", "win.goto"))
 	editMenu.Append(sepMi3)
-	editMenu.Append(selectAllMi)
-	editMenu.Append(m.timedateMenuItem)
+	editMenu.Append(newActionMenuItem("Select All", "win.select-all"))
+	editMenu.Append(newActionMenuItem("Time/Date", "win.insert-time"))
 
 	m.gtkmenuBar.Append(editMain)
-
-	// Setup signals from our textView
-	m.cutMenuItem.SetSensitive(false)
-	m.copyMenuItem.SetSensitive(false)
-	m.deleteMenuItem.SetSensitive(false)
-
 }
 
 func (m *menu) setupFormatMenu() {
 	formatMenu, _ := gtk.MenuNew()
 	formatMain, _ := gtk.MenuItemNewWithLabel("Format")
 
-	m.fontMenuItem, _ = gtk.MenuItemNewWithLabel("Font...")
-	m.wordWrapMenuItem, _ = gtk.CheckMenuItemNewWithLabel("Word Wrap")
+	lineEndingMenu, _ := gtk.MenuNew()
+	lineEndingMi, _ := gtk.MenuItemNewWithLabel("Line Endings")
+	lineEndingMi.SetSubmenu(lineEndingMenu)
+
+	// The items target the action, which draws them as radio items.
+	for _, ending := range lineEndings {
+		lineEndingMenu.Append(newActionCheckMenuItem(ending.String(), "win.line-ending::"+ending.name()))
+	}
 
 	formatMain.SetSubmenu(formatMenu)
-	formatMenu.Append(m.wordWrapMenuItem)
-	formatMenu.Append(m.fontMenuItem)
+	formatMenu.Append(newActionCheckMenuItem("Word Wrap", "win.word-wrap"))
+	formatMenu.Append(newActionMenuItem("Font...", "win.font"))
+	formatMenu.Append(lineEndingMi)
 
 	m.gtkmenuBar.Append(formatMain)
 }
@@ -229,10 +152,38 @@ func (m *menu) setupViewMenu() {
 	viewMenu, _ := gtk.MenuNew()
 	viewMain, _ := gtk.MenuItemNewWithLabel("View")
 
-	m.statusBarMenuItem, _ = gtk.CheckMenuItemNewWithLabel("Status Bar")
+	languageMenu, _ := gtk.MenuNew()
+	languageMi, _ := gtk.MenuItemNewWithLabel("Language")
+	languageMi.SetSubmenu(languageMenu)
+
+	languageMenu.Append(newActionCheckMenuItem("Plain Text", "win.language::"+plainTextID))
+
+	for _, l := range languages {
+		languageMenu.Append(newActionCheckMenuItem(l.Name, "win.language::"+l.ID))
+	}
+
+	schemeMenu, _ := gtk.MenuNew()
+	schemeMi, _ := gtk.MenuItemNewWithLabel("Color Scheme")
+	schemeMi.SetSubmenu(schemeMenu)
+
+	for _, s := range m.app.application.schemes {
+		schemeMenu.Append(newActionCheckMenuItem(s.Name, "app.scheme::"+s.Name))
+	}
+
+	zoomMenu, _ := gtk.MenuNew()
+	zoomMi, _ := gtk.MenuItemNewWithLabel("Zoom")
+	zoomMi.SetSubmenu(zoomMenu)
+
+	zoomMenu.Append(newActionMenuItem("Zoom In", "app.zoom-in"))
+	zoomMenu.Append(newActionMenuItem("Zoom Out", "app.zoom-out"))
+	zoomMenu.Append(newActionMenuItem("Restore Default Zoom", "app.zoom-reset"))
 
 	viewMain.SetSubmenu(viewMenu)
-	viewMenu.Append(m.statusBarMenuItem)
+	viewMenu.Append(zoomMi)
+	viewMenu.Append(newActionCheckMenuItem("Status Bar", "win.status-bar"))
+	viewMenu.Append(newActionCheckMenuItem("Line Numbers", "win.line-numbers"))
+	viewMenu.Append(languageMi)
+	viewMenu.Append(schemeMi)
 
 	m.gtkmenuBar.Append(viewMain)
 }
@@ -241,12 +192,8 @@ func (m *menu) setupHelpMenu() {
 	helpMenu, _ := gtk.MenuNew()
 	helpMain, _ := gtk.MenuItemNewWithLabel("Help")
 
-	m.aboutMenuItem, _ = gtk.MenuItemNewWithLabel("About Go Notepad")
-	key, mod := gtk.AcceleratorParse("F1")
-	m.aboutMenuItem.AddAccelerator("activate", m.app.accelGroup, key, mod, gtk.ACCEL_VISIBLE)
-
 	helpMain.SetSubmenu(helpMenu)
-	helpMenu.Append(m.aboutMenuItem)
+	helpMenu.Append(newActionMenuItem("About Go Notepad", "app.about"))
 
 	m.gtkmenuBar.Append(helpMain)
 }
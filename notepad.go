@@ -1,14 +1,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"syscall"
 
-	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -21,33 +22,37 @@ const (
 )
 
 type (
+	// app is an editor window with its tabs.
 	app struct {
-		openedFilename  string
-		hasChanges      bool
-		isFileOpened    bool
-		lineCount       int
-		lineOffsetCount int
-
-		Win        *gtk.Window
-		textView   *textView
-		menu       *menu
-		accelGroup *gtk.AccelGroup
-		statusBar  *statusbar
-		grid       *gtk.Grid
+		application *application
+
+		Win       *gtk.ApplicationWindow
+		menu      *menu
+		actions   map[string]*glib.SimpleAction
+		statusBar *statusbar
+		grid      *gtk.Grid
+		notebook  *gtk.Notebook
+
+		// doc is the document of the selected tab.
+		doc       *document
+		documents []*document
+
+		// lineNumbers shows the gutter of every tab.
+		lineNumbers bool
+
+		findDialog    *findDialog
+		replaceDialog *findDialog
 
 		config *ConfigSchema
-	}
-)
+		search searchOptions
 
-func (a *app) LoadConfig() {
-	c, err := searchAndLoadConfig()
-	if err != nil {
-		a.UnexpectedErrorMessageBox("Unexpected error parsing config file: %s\n\nUsing defaults", err)
-		c = &DefaultConfig
+		printSettings *gtk.PrintSettings
+		fileWatcher   *fileWatcher
+		recentFiles   *recentFiles
+		fileMetadata  *fileMetadataStore
+		geometry      windowGeometry
 	}
-
-	a.config = c
-}
+)
 
 func (a *app) UnexpectedErrorMessageBox(format string, args ...interface{}) {
 	d := gtk.MessageDialogNew(a.Win, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_ERROR, gtk.BUTTONS_OK, "")
@@ -57,36 +62,53 @@ func (a *app) UnexpectedErrorMessageBox(format string, args ...interface{}) {
 	d.Destroy()
 }
 
-func (a *app) updateStatusBar() {
-	if a.statusBar == nil || !a.menu.statusBarMenuItem.GetActive() {
-		return
+// SaveErrorMessageBox explains why filename could not be saved. Saving is
+// atomic, so the file on disk is always left as it was.
+func (a *app) SaveErrorMessageBox(filename string, err error) {
+	var reason string
+
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		reason = "Access is denied. Make sure you have permission to write to this file and its folder."
+	case errors.Is(err, syscall.ENOSPC):
+		reason = "There is not enough space on the disk."
+	case errors.Is(err, syscall.EROFS):
+		reason = "The file is on a read-only file system."
+	case errors.Is(err, fs.ErrNotExist):
+		reason = "The folder of this file does not exist anymore."
+	default:
+		reason = err.Error()
 	}
 
-	a.statusBar.SetText(fmt.Sprintf("col: %d | line: %d", a.lineOffsetCount+1, a.lineCount))
+	d := gtk.MessageDialogNew(a.Win, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_ERROR, gtk.BUTTONS_OK, "Cannot save %s", filepath.Base(filename))
+	d.FormatSecondaryText("%s\n\nThe file on disk has not been changed.", reason)
+	d.SetTitle(appName)
+	d.Run()
+	d.Destroy()
 }
 
-func (a *app) UpdateTitle() {
-	title := filepath.Base(a.openedFilename) + " - " + appName
-	a.Win.SetTitle(title)
-}
+func (a *app) updateStatusBar() {
+	if a.statusBar == nil || a.doc == nil || !a.statusBar.visible {
+		return
+	}
 
-func (a *app) LoadFile(filename string) {
-	a.openedFilename = filename
-	err := a.textView.LoadSource(filename)
+	d := a.doc
+	a.statusBar.SetText(fmt.Sprintf("col: %d | line: %d | %s | %s | %d%%", d.lineOffsetCount+1, d.lineCount, d.lineEnding, d.encoding.Name, a.application.zoom))
+}
 
-	if err != nil {
-		a.UnexpectedErrorMessageBox("Unexpected error loading file: %s\n\n%s", filename, err)
-	}
+// updateSelectionMenu enables the actions which need selected text.
+func (a *app) updateSelectionMenu() {
+	tb, _ := a.doc.textView.GTKtextView.GetBuffer()
+	selected := tb.GetHasSelection()
 
-	a.hasChanges = false
-	a.isFileOpened = true
-	a.UpdateTitle()
+	a.actions["cut"].SetEnabled(selected)
+	a.actions["copy"].SetEnabled(selected)
+	a.actions["delete"].SetEnabled(selected)
 }
 
-func (a *app) Init(args []string) {
-	if len(args) > 1 && fileExist(args[1]) {
-		a.LoadFile(args[1])
-	}
+func (a *app) UpdateTitle() {
+	title := a.doc.Name() + " - " + appName
+	a.Win.SetTitle(title)
 }
 
 func (a *app) SetupWindow() {
@@ -101,275 +123,84 @@ func (a *app) SetupWindow() {
 	a.grid.SetOrientation(gtk.ORIENTATION_VERTICAL)
 
 	a.menu = newMenu(a)
-	a.textView = newTextView(a)
+	a.recentFiles = newRecentFiles(a)
+	a.setupNotebook()
 	a.statusBar = newStatusbar(a)
 
-	a.UpdateTitle()
+	a.NewDocument()
+
 	a.Win.SetBorderWidth(2)
 	a.Win.SetDefaultSize(defaultWindowWidth, defaultWindowHeight)
 	a.Win.SetPosition(gtk.WIN_POS_CENTER)
-	a.Win.ShowAll()
-
-	a.textView.SetFont(a.config.Font.Family, a.config.Font.Size)
+	a.trackWindowGeometry()
 
-	if a.config.Font.Wrap {
-		a.menu.wordWrapMenuItem.SetActive(a.config.Font.Wrap)
-		a.textView.WrapText(a.config.Font.Wrap)
+	// Only the first window takes the size of the last session.
+	if len(a.application.windows) == 1 {
+		a.RestoreWindowGeometry()
 	}
 
+	a.Win.ShowAll()
+
+	a.doc.textView.SetFont(a.config.Font.Family, a.application.zoomed(a.config.Font.Size))
+
 	if a.config.StatusBar.Enable {
-		a.menu.statusBarMenuItem.SetActive(true)
-		a.statusBar.Show()
-		a.updateStatusBar()
+		a.actions["status-bar"].ChangeState(glib.VariantFromBoolean(true))
 	}
 
-}
-
-func (a *app) displayUnsavedChangesMessagedialog() (response gtk.ResponseType) {
-	d := gtk.MessageDialogNew(
-		a.Win,
-		gtk.DIALOG_DESTROY_WITH_PARENT,
-		gtk.MESSAGE_WARNING,
-		gtk.BUTTONS_YES_NO,
-		"The text in the %s file has changed.",
-		a.openedFilename,
-	)
-	d.FormatSecondaryText("Do you want to save the changes?")
-	d.SetTitle(appName)
-	response = d.Run()
-	d.Destroy()
+	if a.config.LineNumbers.Enable {
+		a.actions["line-numbers"].ChangeState(glib.VariantFromBoolean(true))
+	}
 
-	return
 }
 
-func (a *app) SetupEvents() {
-	tb, _ := a.textView.GTKtextView.GetBuffer()
-	tb.Connect("mark-set", func(tb *gtk.TextBuffer, itr *gtk.TextIter) {
-		if tb.GetHasSelection() {
-			a.menu.cutMenuItem.SetSensitive(true)
-			a.menu.copyMenuItem.SetSensitive(true)
-			a.menu.deleteMenuItem.SetSensitive(true)
-		} else {
-			a.menu.cutMenuItem.SetSensitive(false)
-			a.menu.copyMenuItem.SetSensitive(false)
-			a.menu.deleteMenuItem.SetSensitive(false)
-		}
-
-		a.lineCount = itr.GetLine() + 1
-		a.lineOffsetCount = itr.GetLineOffset()
-
-		a.updateStatusBar()
-	})
-
-	a.textView.GTKtextView.Connect("drag-data-received", func(tv *gtk.TextView, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData, info uint, time uint32) {
-		if a.hasChanges {
-			response := a.displayUnsavedChangesMessagedialog()
-
-			if response == gtk.RESPONSE_CANCEL || response == gtk.RESPONSE_DELETE_EVENT {
-				return
-			}
-		}
-
-		uris := string(data.GetData())
-		// Split by new lines to handle multiple files
-		for _, uri := range strings.Split(uris, "\n") {
-			if uri != "" {
-				filePath := strings.TrimPrefix(uri, "file://")
-				filePath = filePath[:len(filePath)-1]
-				a.LoadFile(filePath)
-				break
-			}
-		}
-
-	})
-
-	tb.Connect("changed", func(tb *gtk.TextBuffer) {
-		a.hasChanges = true
-		a.UpdateTitle()
-	})
-
-	a.menu.openMenuItem.Connect("activate", func() {
-		file := gtk.OpenFileChooserNative("Open File", a.Win)
-
-		if file != nil {
-			a.LoadFile(*file)
-		}
-	})
-
-	a.menu.newMenuItem.Connect("activate", func() {
-		if a.hasChanges {
-			response := a.displayUnsavedChangesMessagedialog()
-
-			if response == gtk.RESPONSE_CANCEL || response == gtk.RESPONSE_DELETE_EVENT {
-				return
-			}
-		}
-
-		a.openedFilename = defaultFilename
-		a.textView.Clear()
-		a.hasChanges = false
-		a.isFileOpened = false
-		a.UpdateTitle()
-	})
-
-	a.menu.saveAsMenuItem.Connect("activate", func() {
-		fc, _ := gtk.FileChooserNativeDialogNew("Save As...", a.Win, gtk.FILE_CHOOSER_ACTION_SAVE, "Save", "Cancel")
-		response := fc.Run()
-		fc.Destroy()
-
-		filename := fc.GetFilename()
-
-		if response == int(gtk.RESPONSE_ACCEPT) {
-			if fileExist(filename) {
-				d := gtk.MessageDialogNew(a.Win, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK_CANCEL, "")
-				d.FormatSecondaryText("You are about to write to a already saved file! Are you sure you wish to do this?")
-				d.SetTitle(appName)
-				response := d.Run()
-				d.Destroy()
-
-				if response == gtk.RESPONSE_CANCEL || response == gtk.RESPONSE_DELETE_EVENT {
-					return
-				}
-			}
-
-			err := a.textView.SaveSource(filename)
-
-			if err != nil {
-				a.UnexpectedErrorMessageBox("Unexpected error saving the file to disk!\n\n%s", err)
-				return
-			}
+func main() {
+	// D-Bus activation starts the editor as a service which is then told what
+	// to open, GApplication handles the option itself.
+	if len(os.Args) == 2 && os.Args[1] == "--gapplication-service" {
+		os.Exit(newApplication(&cliOptions{}, true).Run(os.Args))
+	}
 
-			a.openedFilename = filename
-			a.hasChanges = false
-			a.isFileOpened = true
-			a.UpdateTitle()
-		}
-	})
+	opts, err := parseArgs(os.Args)
 
-	a.menu.saveMenuItem.Connect("activate", func() {
-		if !a.isFileOpened {
-			a.menu.saveAsMenuItem.Emit("activate", glib.TYPE_NONE)
-			return
-		}
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
 
-		err := a.textView.SaveSource(a.openedFilename)
+	if opts.remote != "" {
+		req, err := opts.remoteRequest()
 		if err != nil {
-			a.UnexpectedErrorMessageBox("Unexpected error saving the file to disk!\n\n%s", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
 
-		a.hasChanges = false
-		a.UpdateTitle()
-	})
-
-	a.menu.wordWrapMenuItem.Connect("activate", func() {
-		if a.menu.wordWrapMenuItem.GetActive() {
-			a.textView.WrapText(true)
-		} else {
-			a.textView.WrapText(false)
-		}
-	})
-
-	a.menu.statusBarMenuItem.Connect("activate", func() {
-		if a.menu.statusBarMenuItem.GetActive() {
-			a.statusBar.Show()
-			a.updateStatusBar()
-		} else {
-			a.statusBar.Hide()
+		ok, err := sendToInstance(req)
+		if !ok {
+			err = errors.New("no editor is running in single-instance mode")
 		}
-	})
-
-	a.menu.fontMenuItem.Connect("activate", func() {
-		fd, err := gtk.FontChooserDialogNew(appName, a.Win)
 
 		if err != nil {
-			a.UnexpectedErrorMessageBox("Unexpected error creating font chooser dialog:\n\n%s", err)
-			fmt.Printf("failed creating font chooser dialog: %s\n", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 
-		fd.SetFont(fmt.Sprintf("%s %d", a.config.Font.Family, a.config.Font.Size))
-
-		fd.ShowAll()
-		response := fd.Run()
-
-		if response == gtk.RESPONSE_OK {
-			fontText := fd.GetFont()
+		os.Exit(0)
+	}
 
-			fontTokens := strings.Split(fontText, " ")
-			fontSize, err := strconv.Atoi(fontTokens[len(fontTokens)-1])
+	single := singleInstance(opts.config)
 
+	// The running editor opens the files in new tabs of its window.
+	if single {
+		if ok, err := sendToInstance(opts.request()); ok {
 			if err != nil {
-				a.UnexpectedErrorMessageBox("Unexpected error extracting font size:\n\n%s", err)
-				fmt.Printf("failed selecting font: %s\n", err)
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
 
-			fontFamily := strings.Join(fontTokens[:len(fontTokens)-1], " ")
-			fontFamily = strings.Trim(fontFamily, ",")
-
-			err = a.textView.SetFont(fontFamily, int64(fontSize))
-			if err != nil {
-				a.UnexpectedErrorMessageBox("Unexpected error choosing font:\n\n%s", err)
-			}
+			os.Exit(0)
 		}
-
-		fd.Destroy()
-
-	})
-
-	a.menu.timedateMenuItem.Connect("activate", func() {
-		a.textView.InsertTimestamp()
-	})
-
-	// Handle on-close events.
-	a.menu.exitMenuItem.Connect("activate", func() {
-		a.Win.Close()
-	})
-
-	a.accelGroup.Connect(gdk.KEY_W, gdk.CONTROL_MASK, 0, func() {
-		a.Win.Close()
-	})
-
-	a.menu.aboutMenuItem.Connect("activate", func() {
-		displayAboutDialog(a)
-	})
-}
-
-func main() {
-	gtk.Init(nil)
-	var err error
-
-	app := &app{
-		openedFilename: defaultFilename,
-	}
-
-	app.Win, err = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-
-	if err != nil {
-		log.Fatal("failed creating window:", err)
 	}
 
-	app.Win.Connect("delete-event", func() bool {
-		if app.hasChanges {
-			switch app.displayUnsavedChangesMessagedialog() {
-			case gtk.RESPONSE_NO, gtk.RESPONSE_DELETE_EVENT:
-				return false
-			case gtk.RESPONSE_YES:
-				app.menu.saveMenuItem.Emit("activate", glib.TYPE_NONE)
-				return true
-			}
-		}
-
-		return false
-	})
-
-	app.Win.Connect("destroy", func() {
-		gtk.MainQuit()
-	})
-
-	app.LoadConfig()
-	app.SetupWindow()
-	app.SetupEvents()
-	app.Init(os.Args)
-
-	gtk.Main()
+	os.Exit(newApplication(opts, single).Run(os.Args[:1]))
 }
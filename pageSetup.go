@@ -0,0 +1,207 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// newPageSetup builds the GTK page setup described by the config.
+func (a *app) newPageSetup() *gtk.PageSetup {
+	c := a.config.PageSetup
+
+	ps, err := gtk.PageSetupNew()
+	if err != nil {
+		return nil
+	}
+
+	if paper, err := gtk.PaperSizeNew(c.Paper); err == nil {
+		ps.SetPaperSize(paper)
+	}
+
+	if c.Landscape {
+		ps.SetOrientation(gtk.PAGE_ORIENTATION_LANDSCAPE)
+	} else {
+		ps.SetOrientation(gtk.PAGE_ORIENTATION_PORTRAIT)
+	}
+
+	ps.SetTopMargin(c.MarginTop, gtk.GTK_UNIT_MM)
+	ps.SetBottomMargin(c.MarginBottom, gtk.GTK_UNIT_MM)
+	ps.SetLeftMargin(c.MarginLeft, gtk.GTK_UNIT_MM)
+	ps.SetRightMargin(c.MarginRight, gtk.GTK_UNIT_MM)
+
+	return ps
+}
+
+func displayPageSetupDialog(app *app) {
+	c := app.config.PageSetup
+
+	d, _ := gtk.DialogNew()
+	d.SetTitle("Page Setup")
+	d.SetTransientFor(app.Win)
+	d.SetResizable(false)
+
+	b, _ := d.GetContentArea()
+	b.SetSpacing(5)
+	b.SetMarginTop(10)
+	b.SetMarginStart(10)
+	b.SetMarginEnd(10)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+
+	// Paper
+	paperLabel, _ := gtk.LabelNew("Size:")
+	paperLabel.SetHAlign(gtk.ALIGN_START)
+	paperCombo, _ := gtk.ComboBoxTextNew()
+	paperCombo.Append("", "Default")
+
+	if sizes := gtk.PaperSizeGetPaperSizes(false); sizes != nil {
+		sizes.Foreach(func(item interface{}) {
+			if size, ok := item.(*gtk.PaperSize); ok {
+				paperCombo.Append(size.GetName(), size.GetDisplayName())
+			}
+		})
+	}
+
+	if !paperCombo.SetActiveID(c.Paper) {
+		paperCombo.SetActiveID("")
+	}
+
+	grid.Attach(paperLabel, 0, 0, 1, 1)
+	grid.Attach(paperCombo, 1, 0, 3, 1)
+
+	// Orientation
+	orientationLabel, _ := gtk.LabelNew("Orientation:")
+	orientationLabel.SetHAlign(gtk.ALIGN_START)
+	portrait, _ := gtk.RadioButtonNewWithLabel(nil, "Portrait")
+	landscape, _ := gtk.RadioButtonNewWithLabelFromWidget(portrait, "Landscape")
+	landscape.SetActive(c.Landscape)
+
+	grid.Attach(orientationLabel, 0, 1, 1, 1)
+	grid.Attach(portrait, 1, 1, 1, 1)
+	grid.Attach(landscape, 2, 1, 1, 1)
+
+	// Margins
+	marginSpin := func(label string, value float64, left, top int) *gtk.SpinButton {
+		l, _ := gtk.LabelNew(label)
+		l.SetHAlign(gtk.ALIGN_START)
+		spin, _ := gtk.SpinButtonNewWithRange(0, 200, 0.5)
+		spin.SetDigits(2)
+		spin.SetValue(value)
+
+		grid.Attach(l, left, top, 1, 1)
+		grid.Attach(spin, left+1, top, 1, 1)
+
+		return spin
+	}
+
+	marginsLabel, _ := gtk.LabelNew("Margins (millimeters)")
+	marginsLabel.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(marginsLabel, 0, 2, 4, 1)
+
+	leftSpin := marginSpin("Left:", c.MarginLeft, 0, 3)
+	rightSpin := marginSpin("Right:", c.MarginRight, 2, 3)
+	topSpin := marginSpin("Top:", c.MarginTop, 0, 4)
+	bottomSpin := marginSpin("Bottom:", c.MarginBottom, 2, 4)
+
+	// Header and footer
+	headerLabel, _ := gtk.LabelNew("Header:")
+	headerLabel.SetHAlign(gtk.ALIGN_START)
+	header, _ := gtk.EntryNew()
+	header.SetText(c.Header)
+
+	footerLabel, _ := gtk.LabelNew("Footer:")
+	footerLabel.SetHAlign(gtk.ALIGN_START)
+	footer, _ := gtk.EntryNew()
+	footer.SetText(c.Footer)
+
+	hint, _ := gtk.LabelNew("&f file name, &p page number, &d date, &t time, &l &c &r align left, center or right")
+	hint.SetHAlign(gtk.ALIGN_START)
+	hint.SetLineWrap(true)
+
+	grid.Attach(headerLabel, 0, 5, 1, 1)
+	grid.Attach(header, 1, 5, 3, 1)
+	grid.Attach(footerLabel, 0, 6, 1, 1)
+	grid.Attach(footer, 1, 6, 3, 1)
+	grid.Attach(hint, 0, 7, 4, 1)
+
+	b.PackStart(grid, true, true, 0)
+
+	d.AddButton("OK", gtk.RESPONSE_OK)
+	d.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	d.SetDefaultResponse(gtk.RESPONSE_OK)
+	d.ShowAll()
+
+	if d.Run() == gtk.RESPONSE_OK {
+		c.Paper = paperCombo.GetActiveID()
+		c.Landscape = landscape.GetActive()
+		c.MarginLeft = leftSpin.GetValue()
+		c.MarginRight = rightSpin.GetValue()
+		c.MarginTop = topSpin.GetValue()
+		c.MarginBottom = bottomSpin.GetValue()
+		c.Header, _ = header.GetText()
+		c.Footer, _ = footer.GetText()
+
+		app.config.PageSetup = c
+		app.application.SaveConfig(map[string]interface{}{
+			"pagesetup.paper":        c.Paper,
+			"pagesetup.landscape":    c.Landscape,
+			"pagesetup.marginleft":   c.MarginLeft,
+			"pagesetup.marginright":  c.MarginRight,
+			"pagesetup.margintop":    c.MarginTop,
+			"pagesetup.marginbottom": c.MarginBottom,
+			"pagesetup.header":       c.Header,
+			"pagesetup.footer":       c.Footer,
+		})
+	}
+
+	d.Destroy()
+}
+
+// formatHeaderFooter expands the Notepad header and footer codes in format
+// into its left, center and right aligned parts. Text is centered until one
+// of &l, &c or &r switches the alignment.
+func formatHeaderFooter(format, filename string, page int, now time.Time) (parts [3]string) {
+	align := 1
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '&' || i+1 == len(format) {
+			parts[align] += format[i : i+1]
+			continue
+		}
+
+		i++
+
+		switch format[i] {
+		case 'f', 'F':
+			parts[align] += filepath.Base(filename)
+		case 'p', 'P':
+			parts[align] += strconv.Itoa(page)
+		case 'd', 'D':
+			parts[align] += now.Format("02/01/2006")
+		case 't', 'T':
+			parts[align] += now.Format("3:04 PM")
+		case 'l', 'L':
+			align = 0
+		case 'c', 'C':
+			align = 1
+		case 'r', 'R':
+			align = 2
+		case '&':
+			parts[align] += "&"
+		default:
+			parts[align] += format[i-1 : i+1]
+		}
+	}
+
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return
+}
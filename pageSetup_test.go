@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatHeaderFooter(t *testing.T) {
+	now := time.Date(2026, time.March, 7, 14, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		format string
+		want   [3]string
+	}{
+		{"&f", [3]string{"", "notes.txt", ""}},
+		{"&F", [3]string{"", "notes.txt", ""}},
+		{"Page &p", [3]string{"", "Page 3", ""}},
+		{"&d &t", [3]string{"", "07/03/2026 2:05 PM", ""}},
+		{"Fish && Chips", [3]string{"", "Fish & Chips", ""}},
+		{"&l&f&rPage &p", [3]string{"notes.txt", "", "Page 3"}},
+		{"&lLeft&cCenter&rRight", [3]string{"Left", "Center", "Right"}},
+		{"&x marks", [3]string{"", "&x marks", ""}},
+		{"Total &", [3]string{"", "Total &", ""}},
+		{"", [3]string{"", "", ""}},
+	}
+
+	for _, tt := range tests {
+		if got := formatHeaderFooter(tt.format, "/home/user/notes.txt", 3, now); got != tt.want {
+			t.Errorf("formatHeaderFooter(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
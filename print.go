@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+type (
+	// printJob lays the document out into pages for a single print operation.
+	printJob struct {
+		text     string
+		filename string
+		header   string
+		footer   string
+		time     time.Time
+		font     *pango.FontDescription
+		wrap     bool
+		pages    [][]string
+
+		// headerHeight and footerHeight are the space reserved at the top and
+		// bottom of every page, zero when there is no header or footer.
+		headerHeight float64
+		footerHeight float64
+	}
+)
+
+// Print runs a print operation for the document. action selects between the
+// print dialog, a preview or exporting straight to the PDF file filename.
+func (a *app) Print(action gtk.PrintOperationAction, filename string) {
+	op, err := gtk.PrintOperationNew()
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error creating print operation:\n\n%s", err)
+		return
+	}
+
+	op.SetJobName(filepath.Base(a.doc.openedFilename))
+	op.SetUnit(gtk.GTK_UNIT_POINTS)
+	op.SetUseFullPage(false)
+
+	if a.printSettings != nil {
+		op.SetPrintSettings(a.printSettings)
+	}
+
+	if ps := a.newPageSetup(); ps != nil {
+		op.SetDefaultPageSetup(ps)
+	}
+
+	if filename != "" {
+		op.SetExportFilename(filename)
+	}
+
+	job := &printJob{
+		text:     a.doc.textView.Text(),
+		filename: a.doc.openedFilename,
+		header:   a.config.PageSetup.Header,
+		footer:   a.config.PageSetup.Footer,
+		time:     time.Now(),
+		font:     pango.FontDescriptionFromString(fmt.Sprintf("%s %d", a.config.Font.Family, a.config.Font.Size)),
+		wrap:     a.doc.wrap,
+	}
+
+	op.Connect("begin-print", func(op *gtk.PrintOperation, ctx *gtk.PrintContext) {
+		job.paginate(ctx)
+		op.SetNPages(len(job.pages))
+	})
+
+	op.Connect("draw-page", func(_ *gtk.PrintOperation, ctx *gtk.PrintContext, page int) {
+		job.drawPage(ctx, page)
+	})
+
+	result, err := op.Run(action, a.Win)
+
+	if err != nil {
+		a.UnexpectedErrorMessageBox("Unexpected error printing %s:\n\n%s", a.doc.openedFilename, err)
+		return
+	}
+
+	if result == gtk.PRINT_OPERATION_RESULT_APPLY {
+		a.printSettings, _ = op.GetPrintSettings(nil)
+	}
+}
+
+// ExportPDF asks for a file name and prints the document into it as a PDF.
+func (a *app) ExportPDF() {
+	fc, _ := gtk.FileChooserNativeDialogNew("Export to PDF", a.Win, gtk.FILE_CHOOSER_ACTION_SAVE, "Export", "Cancel")
+	fc.SetDoOverwriteConfirmation(true)
+	fc.SetCurrentName(strings.TrimSuffix(filepath.Base(a.doc.openedFilename), filepath.Ext(a.doc.openedFilename)) + ".pdf")
+	response := fc.Run()
+	filename := fc.GetFilename()
+	fc.Destroy()
+
+	if response != int(gtk.RESPONSE_ACCEPT) {
+		return
+	}
+
+	a.Print(gtk.PRINT_OPERATION_ACTION_EXPORT, filename)
+}
+
+func (j *printJob) newLayout(ctx *gtk.PrintContext) *pango.Layout {
+	layout := ctx.CreatePangoLayout()
+	layout.SetFontDescription(j.font)
+
+	if j.wrap {
+		layout.SetWidth(int(ctx.GetWidth() * pango.PANGO_SCALE))
+		layout.SetWrap(pango.WRAP_WORD_CHAR)
+	}
+
+	return layout
+}
+
+// paginate splits the text into pages of lines which fit the printable area,
+// breaking up wrapped paragraphs taller than the remaining space.
+func (j *printJob) paginate(ctx *gtk.PrintContext) {
+	layout := j.newLayout(ctx)
+
+	measure := func(text string) float64 {
+		layout.SetText(text, -1)
+		_, h := layout.GetSize()
+		return float64(h) / pango.PANGO_SCALE
+	}
+
+	// Leave a blank line between the header or footer and the text.
+	j.headerHeight, j.footerHeight = 0, 0
+
+	if j.header != "" {
+		j.headerHeight = measure("") * 2
+	}
+
+	if j.footer != "" {
+		j.footerHeight = measure("") * 2
+	}
+
+	pageHeight := ctx.GetHeight() - j.headerHeight - j.footerHeight
+
+	j.pages = nil
+	var page []string
+	used := 0.0
+
+	for _, line := range strings.Split(j.text, "\n") {
+		for {
+			h := measure(line)
+
+			if used+h <= pageHeight {
+				page = append(page, line)
+				used += h
+				break
+			}
+
+			n := fitRunes(measure, line, pageHeight-used)
+
+			if n > 0 {
+				runes := []rune(line)
+				page = append(page, string(runes[:n]))
+				line = string(runes[n:])
+			}
+
+			// A single line taller than a page can not be split any further.
+			if n == 0 && len(page) == 0 {
+				page = append(page, line)
+				line = ""
+			}
+
+			j.pages = append(j.pages, page)
+			page, used = nil, 0
+
+			if line == "" {
+				break
+			}
+		}
+	}
+
+	if len(page) > 0 || len(j.pages) == 0 {
+		j.pages = append(j.pages, page)
+	}
+}
+
+// fitRunes returns how many runes from the start of line fit into height,
+// preferring to break after a space like the on screen word wrap does.
+func fitRunes(measure func(string) float64, line string, height float64) int {
+	runes := []rune(line)
+	lo, hi := 0, len(runes)
+
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+
+		if measure(string(runes[:mid])) <= height {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	if i := strings.LastIndex(string(runes[:lo]), " "); i > 0 {
+		return len([]rune(string(runes[:lo])[:i+1]))
+	}
+
+	return lo
+}
+
+func (j *printJob) drawPage(ctx *gtk.PrintContext, page int) {
+	if page >= len(j.pages) {
+		return
+	}
+
+	cr := ctx.GetCairoContext()
+	layout := j.newLayout(ctx)
+	layout.SetText(strings.Join(j.pages[page], "\n"), -1)
+
+	cr.MoveTo(0, j.headerHeight)
+	pango.CairoShowLayout(cr, layout)
+
+	if j.header != "" {
+		j.drawHeaderFooter(ctx, j.header, page, 0)
+	}
+
+	if j.footer != "" {
+		j.drawHeaderFooter(ctx, j.footer, page, ctx.GetHeight()-j.footerHeight/2)
+	}
+}
+
+// drawHeaderFooter draws a single line header or footer at y, placing its
+// left, center and right parts across the width of the page.
+func (j *printJob) drawHeaderFooter(ctx *gtk.PrintContext, format string, page int, y float64) {
+	cr := ctx.GetCairoContext()
+	width := ctx.GetWidth()
+
+	layout := ctx.CreatePangoLayout()
+	layout.SetFontDescription(j.font)
+
+	for align, text := range formatHeaderFooter(format, j.filename, page+1, j.time) {
+		if text == "" {
+			continue
+		}
+
+		layout.SetText(text, -1)
+		w, _ := layout.GetSize()
+		textWidth := float64(w) / pango.PANGO_SCALE
+
+		x := 0.0
+		switch align {
+		case 1:
+			x = (width - textWidth) / 2
+		case 2:
+			x = width - textWidth
+		}
+
+		cr.MoveTo(x, y)
+		pango.CairoShowLayout(cr, layout)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "syscall"
+
+const processQueryLimitedInformation = 0x1000
+
+// processAlive reports whether a process with pid is still running.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+
+	// STILL_ACTIVE
+	return code == 259
+}
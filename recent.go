@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const recentFilesState = "recent.json"
+
+type (
+	// recentFiles is the most recently used list shown in File > Recent Files.
+	// It is kept in the state directory and the files are also added to the
+	// desktop's recently-used.xbel so they show up in GTK's own recent list.
+	recentFiles struct {
+		app     *app
+		files   []string
+		items   []*gtk.MenuItem
+		manager *gtk.RecentManager
+	}
+)
+
+func newRecentFiles(app *app) *recentFiles {
+	r := &recentFiles{app: app}
+
+	if err := loadState(recentFilesState, &r.files); err != nil {
+		fmt.Printf("failed loading recent files: %s\n", err)
+	}
+
+	r.manager, _ = gtk.RecentManagerGetDefault()
+	r.trim()
+
+	// Files may have been deleted since the menu was built, or opened in
+	// another window.
+	app.menu.recentMenuItem.Connect("select", func() {
+		r.reload()
+		r.updateMenu()
+	})
+
+	r.updateMenu()
+
+	return r
+}
+
+// reload reads the list saved by the other windows.
+func (r *recentFiles) reload() {
+	var files []string
+
+	if err := loadState(recentFilesState, &files); err == nil {
+		r.files = files
+		r.trim()
+	}
+}
+
+// Add moves filename to the top of the list.
+func (r *recentFiles) Add(filename string) {
+	if r.app.config.Recent.Size <= 0 {
+		return
+	}
+
+	filename = absPath(filename)
+	r.reload()
+	r.remove(filename)
+	r.files = append([]string{filename}, r.files...)
+	r.trim()
+	r.save()
+
+	if uri, err := filenameToURI(filename); err == nil && r.manager != nil {
+		r.manager.AddItem(uri)
+	}
+}
+
+// Clear empties the list, the desktop's recent list is left alone.
+func (r *recentFiles) Clear() {
+	r.files = nil
+	r.save()
+}
+
+func (r *recentFiles) remove(filename string) {
+	for i, f := range r.files {
+		if f == filename {
+			r.files = append(r.files[:i], r.files[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *recentFiles) trim() {
+	size := r.app.config.Recent.Size
+	if size < 0 {
+		size = 0
+	}
+
+	if len(r.files) > size {
+		r.files = r.files[:size]
+	}
+}
+
+func (r *recentFiles) save() {
+	if err := saveState(recentFilesState, r.files); err != nil {
+		fmt.Printf("failed saving recent files: %s\n", err)
+	}
+
+	r.updateMenu()
+}
+
+// updateMenu rebuilds the submenu, graying out files which no longer exist.
+func (r *recentFiles) updateMenu() {
+	for _, mi := range r.items {
+		mi.Destroy()
+	}
+
+	r.items = nil
+
+	for i, filename := range r.files {
+		filename := filename
+
+		// Underscores would be taken as mnemonics.
+		label := strings.ReplaceAll(filepath.Base(filename), "_", "__")
+		if i < 9 {
+			label = fmt.Sprintf("_%d %s", i+1, label)
+		}
+
+		mi, _ := gtk.MenuItemNewWithMnemonic(label)
+		mi.SetTooltipText(filename)
+		mi.SetSensitive(fileExist(filename))
+		mi.Connect("activate", func() {
+			r.app.OpenFile(filename, nil)
+		})
+
+		r.items = append(r.items, mi)
+	}
+
+	if len(r.files) == 0 {
+		mi, _ := gtk.MenuItemNewWithLabel("(Empty)")
+		mi.SetSensitive(false)
+		r.items = append(r.items, mi)
+	} else {
+		sep, _ := gtk.SeparatorMenuItemNew()
+		clear, _ := gtk.MenuItemNewWithLabel("Clear Recent Files")
+		clear.Connect("activate", func() {
+			r.Clear()
+		})
+
+		r.items = append(r.items, &sep.MenuItem, clear)
+	}
+
+	for _, mi := range r.items {
+		r.app.menu.recentMenu.Append(mi)
+	}
+
+	r.app.menu.recentMenu.ShowAll()
+}
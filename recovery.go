@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	responseRestore gtk.ResponseType = iota + 1
+	responseCompare
+	responseDiscard
+)
+
+type (
+	// recoverySnapshot is the journal entry written for a document with
+	// unsaved changes.
+	recoverySnapshot struct {
+		Filename   string
+		Encoding   string
+		LineEnding lineEnding
+		Text       string
+	}
+
+	// recoveryJournal holds the snapshots of all documents with unsaved
+	// changes in one editor process.
+	recoveryJournal struct {
+		Modified  time.Time
+		Documents []recoverySnapshot
+
+		path string
+	}
+
+	// recovery periodically snapshots the unsaved documents so they can be
+	// restored if the process dies before they are saved.
+	recovery struct {
+		application *application
+		dir         string
+		path        string
+		last        []byte
+	}
+)
+
+func newRecovery(ap *application) *recovery {
+	dir := ap.config.Recovery.Directory
+
+	if dir == "" {
+		dir = filepath.Join(getCacheDir(), "go-notepad", "recovery")
+	}
+
+	return &recovery{
+		application: ap,
+		dir:         dir,
+		path:        filepath.Join(dir, strconv.Itoa(os.Getpid())+".json"),
+	}
+}
+
+// Start snapshots the documents every configured interval.
+func (r *recovery) Start() {
+	if !r.application.config.Recovery.Enable || r.application.config.Recovery.Interval <= 0 {
+		return
+	}
+
+	glib.TimeoutSecondsAdd(uint(r.application.config.Recovery.Interval), func() bool {
+		r.snapshot()
+		return true
+	})
+}
+
+func (r *recovery) snapshot() {
+	var snapshots []recoverySnapshot
+
+	for _, d := range r.application.documents() {
+		if !d.hasChanges {
+			continue
+		}
+
+		filename := ""
+		if d.isFileOpened {
+			filename = d.openedFilename
+		}
+
+		snapshots = append(snapshots, recoverySnapshot{
+			Filename:   filename,
+			Encoding:   d.encoding.Name,
+			LineEnding: d.lineEnding,
+			Text:       d.textView.Text(),
+		})
+	}
+
+	if len(snapshots) == 0 {
+		r.Clear()
+		return
+	}
+
+	// Only write when something changed since the last snapshot.
+	data, err := json.Marshal(snapshots)
+	if err != nil || bytes.Equal(data, r.last) {
+		return
+	}
+
+	err = r.write(recoveryJournal{Modified: time.Now(), Documents: snapshots})
+
+	if err != nil {
+		fmt.Printf("failed writing recovery snapshot: %s\n", err)
+		return
+	}
+
+	r.last = data
+}
+
+func (r *recovery) write(j recoveryJournal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(r.path, data)
+}
+
+// Clear removes the snapshots of this process, done once the documents are
+// saved or discarded and when the editor closes normally.
+func (r *recovery) Clear() {
+	if err := os.Remove(r.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("failed removing recovery snapshot: %s\n", err)
+	}
+
+	r.last = nil
+}
+
+// orphans returns the journals left behind by editors which are no longer
+// running, newest first.
+func (r *recovery) orphans() []*recoveryJournal {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+
+	var journals []*recoveryJournal
+
+	for _, e := range entries {
+		pid, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+
+		if err != nil || !strings.HasSuffix(e.Name(), ".json") || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+
+		path := filepath.Join(r.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		j := &recoveryJournal{path: path}
+		if err := json.Unmarshal(data, j); err != nil {
+			fmt.Printf("ignoring unreadable recovery snapshot %s: %s\n", path, err)
+			continue
+		}
+
+		journals = append(journals, j)
+	}
+
+	sort.Slice(journals, func(i, k int) bool {
+		return journals[i].Modified.After(journals[k].Modified)
+	})
+
+	return journals
+}
+
+// Recover offers to restore, compare or discard the documents recovered from
+// editors which did not exit cleanly. Restored documents open in new tabs,
+// documents whose dialog was dismissed are offered again on the next launch.
+func (r *recovery) Recover(a *app) {
+	if !r.application.config.Recovery.Enable {
+		return
+	}
+
+	for _, j := range r.orphans() {
+		var kept []recoverySnapshot
+
+		for i := range j.Documents {
+			s := &j.Documents[i]
+
+			switch r.displayRecoveryDialog(a, s, j.Modified) {
+			case responseRestore:
+				r.restore(a, s)
+			case responseDiscard:
+			default:
+				kept = append(kept, *s)
+			}
+		}
+
+		if len(kept) == 0 {
+			os.Remove(j.path)
+			continue
+		}
+
+		j.Documents = kept
+		if data, err := json.Marshal(j); err == nil {
+			writeFileAtomic(j.path, data)
+		}
+	}
+}
+
+func (r *recovery) restore(a *app, s *recoverySnapshot) {
+	enc := encodingByName(s.Encoding)
+	if enc == nil {
+		enc = encodingUTF8
+	}
+
+	d := a.blankDocument()
+	d.textView.SetText(s.Text)
+	d.textView.history.Reset()
+	d.textView.history.ForgetSavePoint()
+
+	if s.Filename != "" {
+		d.openedFilename = s.Filename
+		d.isFileOpened = true
+		d.Watch(s.Filename)
+	}
+
+	d.encoding = enc
+	d.SetLineEnding(s.LineEnding)
+	d.detectLanguage()
+	d.hasChanges = true
+	d.UpdateTitle()
+}
+
+func (s *recoverySnapshot) displayName() string {
+	if s.Filename == "" {
+		return defaultFilename
+	}
+
+	return s.Filename
+}
+
+func (r *recovery) displayRecoveryDialog(a *app, s *recoverySnapshot, modified time.Time) (response gtk.ResponseType) {
+	d := gtk.MessageDialogNew(
+		a.Win,
+		gtk.DIALOG_DESTROY_WITH_PARENT,
+		gtk.MESSAGE_QUESTION,
+		gtk.BUTTONS_NONE,
+		"Unsaved changes to %s were recovered.",
+		s.displayName(),
+	)
+	d.FormatSecondaryText("%s did not close properly. The changes were last saved for recovery on %s.", appName, modified.Format("02/01/2006 3:04 PM"))
+	d.SetTitle(appName)
+	d.AddButton("Discard", responseDiscard)
+
+	if s.Filename != "" && fileExist(s.Filename) {
+		d.AddButton("Compare", responseCompare)
+	}
+
+	d.AddButton("Restore", responseRestore)
+	d.SetDefaultResponse(responseRestore)
+
+	for {
+		response = d.Run()
+
+		if response != responseCompare {
+			break
+		}
+
+		r.displayCompareWindow(a, s)
+	}
+
+	d.Destroy()
+
+	return
+}
+
+// displayCompareWindow shows the file on disk next to the recovered text.
+func (r *recovery) displayCompareWindow(a *app, s *recoverySnapshot) {
+	d, _ := gtk.DialogNew()
+	d.SetTitle("Compare - " + filepath.Base(s.Filename))
+	d.SetTransientFor(a.Win)
+	d.SetDefaultSize(defaultWindowWidth, defaultWindowHeight)
+
+	b, _ := d.GetContentArea()
+
+	paned, _ := gtk.PanedNew(gtk.ORIENTATION_HORIZONTAL)
+	paned.SetVExpand(true)
+
+	var onDisk string
+	src, err := os.ReadFile(s.Filename)
+
+	if err != nil {
+		onDisk = fmt.Sprintf("Unable to read the file on disk:\n\n%s", err)
+	} else {
+		// Fall back to detecting the encoding when the journal names none or
+		// one which is not known.
+		enc := encodingByName(s.Encoding)
+		if enc == nil {
+			enc = detectEncoding(src)
+		}
+
+		if text, err := enc.Decode(src); err != nil {
+			onDisk = fmt.Sprintf("Unable to decode the file on disk:\n\n%s", err)
+		} else {
+			onDisk = normalizeLineEndings(text)
+		}
+	}
+
+	paned.Pack1(newComparePane("On disk", onDisk), true, true)
+	paned.Pack2(newComparePane("Recovered", s.Text), true, true)
+	paned.SetPosition(defaultWindowWidth / 2)
+
+	b.PackStart(paned, true, true, 0)
+
+	d.AddButton("Close", gtk.RESPONSE_CLOSE)
+	d.ShowAll()
+	d.Run()
+	d.Destroy()
+}
+
+func newComparePane(title, text string) *gtk.Frame {
+	frame, _ := gtk.FrameNew(title)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+
+	tv, _ := gtk.TextViewNew()
+	tv.SetEditable(false)
+	tv.SetMonospace(true)
+
+	buff, _ := tv.GetBuffer()
+	buff.SetText(text)
+
+	scrolled.Add(tv)
+	frame.Add(scrolled)
+
+	return frame
+}
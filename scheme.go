@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// colorScheme are the colors of the text, its selection and highlighting,
+	// the gutter and the status bar. Dark schemes make GTK use the dark
+	// variant of the theme for the rest of the window.
+	colorScheme struct {
+		Name string
+		Dark bool
+
+		Background    string
+		Foreground    string
+		Selection     string
+		SelectionText string
+		Gutter        string
+		GutterText    string
+		StatusBar     string
+		StatusBarText string
+
+		// Syntax are the colors of the highlighting tags, such as "comment".
+		Syntax map[string]string
+	}
+)
+
+var (
+	lightScheme = colorScheme{
+		Name:          "light",
+		Background:    "#ffffff",
+		Foreground:    "#000000",
+		Selection:     "#3399ff",
+		SelectionText: "#ffffff",
+		Gutter:        "#f0f0f0",
+		GutterText:    "#808080",
+		StatusBar:     "#f0f0f0",
+		StatusBarText: "#000000",
+		Syntax:        highlightColors,
+	}
+
+	darkScheme = colorScheme{
+		Name:          "dark",
+		Dark:          true,
+		Background:    "#1e1e1e",
+		Foreground:    "#d4d4d4",
+		Selection:     "#264f78",
+		SelectionText: "#ffffff",
+		Gutter:        "#252526",
+		GutterText:    "#858585",
+		StatusBar:     "#2d2d2d",
+		StatusBarText: "#cccccc",
+		Syntax: map[string]string{
+			"comment": "#6a9955",
+			"string":  "#ce9178",
+			"keyword": "#569cd6",
+			"type":    "#4ec9b0",
+			"number":  "#b5cea8",
+		},
+	}
+
+	highContrastScheme = colorScheme{
+		Name:          "high-contrast",
+		Dark:          true,
+		Background:    "#000000",
+		Foreground:    "#ffffff",
+		Selection:     "#ffff00",
+		SelectionText: "#000000",
+		Gutter:        "#000000",
+		GutterText:    "#ffffff",
+		StatusBar:     "#000000",
+		StatusBarText: "#ffffff",
+		Syntax: map[string]string{
+			"comment": "#00ff00",
+			"string":  "#ffff00",
+			"keyword": "#00ffff",
+			"type":    "#ff80ff",
+			"number":  "#ff8000",
+		},
+	}
+)
+
+// schemeCSS styles the widgets with the colors of a scheme.
+const schemeCSS = `
+textview, textview text {
+	background-color: %[1]s;
+	color: %[2]s;
+}
+
+textview text selection {
+	background-color: %[3]s;
+	color: %[4]s;
+}
+
+.gutter {
+	background-color: %[5]s;
+	color: %[6]s;
+}
+
+statusbar {
+	background-color: %[7]s;
+	color: %[8]s;
+}
+`
+
+// copy returns a copy of s which can be changed without changing s.
+func (s colorScheme) copy() *colorScheme {
+	syntax := make(map[string]string, len(s.Syntax))
+	for k, v := range s.Syntax {
+		syntax[k] = v
+	}
+
+	s.Syntax = syntax
+
+	return &s
+}
+
+// css returns the style sheet of the scheme.
+func (s *colorScheme) css() string {
+	return fmt.Sprintf(schemeCSS, s.Background, s.Foreground, s.Selection, s.SelectionText, s.Gutter, s.GutterText, s.StatusBar, s.StatusBarText)
+}
+
+// syntaxColor returns the color of the highlighting tag, falling back to the
+// light scheme for tags the scheme leaves out.
+func (s *colorScheme) syntaxColor(tag string) string {
+	if color, ok := s.Syntax[tag]; ok {
+		return color
+	}
+
+	return highlightColors[tag]
+}
+
+// schemeDirectory is where user schemes are read from.
+func schemeDirectory(c *ConfigSchema) string {
+	if c.Scheme.Directory != "" {
+		return c.Scheme.Directory
+	}
+
+	return filepath.Join(stateDir(), "schemes")
+}
+
+// loadColorSchemes returns the built in schemes followed by the user schemes
+// in the scheme directory. A user scheme is a YAML file with the fields of
+// colorScheme, those it leaves out are taken from the light scheme. It is
+// named after the file unless it sets a name, and replaces a built in scheme
+// of the same name.
+func loadColorSchemes(c *ConfigSchema) []*colorScheme {
+	schemes := []*colorScheme{lightScheme.copy(), darkScheme.copy(), highContrastScheme.copy()}
+
+	dir := schemeDirectory(c)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.y*ml"))
+
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Printf("failed reading color scheme %s: %s\n", filename, err)
+			continue
+		}
+
+		s := lightScheme.copy()
+		s.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+		if err := yaml.Unmarshal(data, s); err != nil {
+			fmt.Printf("failed parsing color scheme %s: %s\n", filename, err)
+			continue
+		}
+
+		replaced := false
+		for i, builtin := range schemes {
+			if builtin.Name == s.Name {
+				schemes[i] = s
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			schemes = append(schemes, s)
+		}
+	}
+
+	return schemes
+}
+
+// colorScheme returns the scheme named name, or the light scheme when there
+// is none.
+func (ap *application) colorScheme(name string) *colorScheme {
+	for _, s := range ap.schemes {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return ap.schemes[0]
+}
+
+// watchDesktopScheme applies the dark scheme when the desktop asks for dark
+// applications, if the config follows it.
+func (ap *application) watchDesktopScheme() {
+	// GNOME and the desktops which share its settings have a color-scheme
+	// preference, older ones only a dark theme.
+	const schema, key = "org.gnome.desktop.interface", "color-scheme"
+
+	if source := glib.SettingsSchemaSourceGetDefault(); source != nil {
+		if s := source.Lookup(schema, true); s != nil && s.HasKey(key) {
+			ap.desktopSettings = glib.SettingsNew(schema)
+			ap.desktopSettings.Connect("changed::"+key, func() {
+				ap.applyScheme()
+			})
+		}
+	}
+
+	if settings, err := gtk.SettingsGetDefault(); err == nil {
+		settings.Connect("notify::gtk-theme-name", func() {
+			ap.applyScheme()
+		})
+	}
+}
+
+// desktopPrefersDark reports whether the desktop asks for dark applications.
+func (ap *application) desktopPrefersDark() bool {
+	if ap.desktopSettings != nil && ap.desktopSettings.GetString("color-scheme") == "prefer-dark" {
+		return true
+	}
+
+	settings, err := gtk.SettingsGetDefault()
+	if err != nil {
+		return false
+	}
+
+	theme, _ := settings.GetProperty("gtk-theme-name")
+	name, _ := theme.(string)
+
+	return strings.Contains(strings.ToLower(name), "dark")
+}
+
+// applyScheme colors every window with the scheme of the config, or its dark
+// scheme when it follows the desktop and that prefers dark.
+func (ap *application) applyScheme() {
+	name := ap.config.Scheme.Name
+	if ap.config.Scheme.FollowDesktop && ap.desktopPrefersDark() {
+		name = ap.config.Scheme.Dark
+	}
+
+	s := ap.colorScheme(name)
+	ap.scheme = s
+
+	screen, err := gdk.ScreenGetDefault()
+	if err != nil {
+		fmt.Printf("failed applying color scheme: %s\n", err)
+		return
+	}
+
+	if ap.schemeProvider == nil {
+		ap.schemeProvider, err = gtk.CssProviderNew()
+		if err != nil {
+			fmt.Printf("failed applying color scheme: %s\n", err)
+			return
+		}
+
+		gtk.AddProviderForScreen(screen, ap.schemeProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	}
+
+	if err := ap.schemeProvider.LoadFromData(s.css()); err != nil {
+		fmt.Printf("failed applying color scheme %s: %s\n", s.Name, err)
+	}
+
+	if settings, err := gtk.SettingsGetDefault(); err == nil {
+		settings.SetProperty("gtk-application-prefer-dark-theme", s.Dark)
+	}
+
+	for _, d := range ap.documents() {
+		d.textView.highlighter.SetColors(s)
+	}
+
+	ap.actions["scheme"].SetState(glib.VariantFromString(s.Name))
+}
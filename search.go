@@ -0,0 +1,203 @@
+package main
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	searchOptions struct {
+		query     string
+		matchCase bool
+		wholeWord bool
+		regex     bool
+		up        bool
+		wrap      bool
+	}
+
+	// searcher is a compiled set of search options.
+	searcher struct {
+		re        *regexp.Regexp
+		wholeWord bool
+		expand    bool
+	}
+)
+
+// compile turns the search options into a searcher so plain, case insensitive
+// and regular expression searches all share the same matching code.
+func (o searchOptions) compile() (*searcher, error) {
+	pattern := o.query
+
+	if !o.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	flags := ""
+	if !o.matchCase {
+		flags = "(?i)"
+	}
+
+	re, err := regexp.Compile(flags + pattern)
+
+	if err != nil {
+		// Report the error against the pattern the way the user typed it,
+		// when the pattern alone is already invalid.
+		if _, typedErr := regexp.Compile(pattern); typedErr != nil {
+			return nil, typedErr
+		}
+
+		return nil, err
+	}
+
+	return &searcher{
+		re:        re,
+		wholeWord: o.wholeWord,
+		expand:    o.regex,
+	}, nil
+}
+
+// matches returns the submatch indices of every match in text. Empty matches,
+// such as of "^" or "x*", are left out as there is nothing to select, so Find
+// and Replace All agree on what matches.
+func (s *searcher) matches(text string) [][]int {
+	all := s.re.FindAllStringSubmatchIndex(text, -1)
+	matches := all[:0]
+
+	for _, m := range all {
+		if m[0] == m[1] || (s.wholeWord && !isWholeWord(text, m[0], m[1])) {
+			continue
+		}
+
+		matches = append(matches, m)
+	}
+
+	return matches
+}
+
+// replacement returns the text to substitute for match m, expanding $1 and
+// ${name} references to capture groups in regular expression mode.
+func (s *searcher) replacement(text string, m []int, template string) string {
+	if !s.expand {
+		return template
+	}
+
+	return string(s.re.ExpandString(nil, template, text, m))
+}
+
+// isWholeWord reports whether the byte range start to end of text is not
+// directly surrounded by other word characters.
+func isWholeWord(text string, start, end int) bool {
+	if start == end {
+		return false
+	}
+
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+
+	if r, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(r) {
+		return false
+	}
+
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// findMatch returns the byte offsets of the next non-empty match in text,
+// searching forward from or backward before the byte offset from.
+func findMatch(s *searcher, text string, from int, up, wrap bool) (start, end int, ok bool) {
+	var first, last, before, after []int
+
+	for _, m := range s.matches(text) {
+		if first == nil {
+			first = m
+		}
+		last = m
+
+		if m[1] <= from {
+			before = m
+		}
+
+		if m[0] >= from && after == nil {
+			after = m
+		}
+	}
+
+	var match []int
+
+	switch {
+	case up && before != nil:
+		match = before
+	case !up && after != nil:
+		match = after
+	case wrap && up:
+		match = last
+	case wrap:
+		match = first
+	}
+
+	if match == nil {
+		return 0, 0, false
+	}
+
+	return match[0], match[1], true
+}
+
+// matchAt returns the submatch indices of the match spanning exactly the byte
+// offsets start to end of text, or nil when there is none.
+func matchAt(s *searcher, text string, start, end int) []int {
+	if start == end {
+		return nil
+	}
+
+	for _, m := range s.matches(text) {
+		if m[0] == start && m[1] == end {
+			return m
+		}
+
+		if m[0] > start {
+			break
+		}
+	}
+
+	return nil
+}
+
+// matchCharOffsets converts the byte offsets of matches into GTK character
+// offsets in a single pass over text.
+func matchCharOffsets(text string, matches [][]int) [][2]int {
+	offsets := make([][2]int, len(matches))
+	lastByte, lastChar := 0, 0
+
+	for i, m := range matches {
+		start := lastChar + utf8.RuneCountInString(text[lastByte:m[0]])
+		end := start + utf8.RuneCountInString(text[m[0]:m[1]])
+		offsets[i] = [2]int{start, end}
+		lastByte, lastChar = m[1], end
+	}
+
+	return offsets
+}
+
+// charToByteOffset converts a GTK character offset into a byte offset of text.
+func charToByteOffset(text string, offset int) int {
+	i := 0
+
+	for b := range text {
+		if i == offset {
+			return b
+		}
+		i++
+	}
+
+	return len(text)
+}
+
+// byteToCharOffset converts a byte offset of text into a GTK character offset.
+func byteToCharOffset(text string, offset int) int {
+	return utf8.RuneCountInString(text[:offset])
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    searchOptions
+		text    string
+		want    string
+		invalid bool
+	}{
+		{"plain", searchOptions{query: "a.c", matchCase: true}, "abc a.c", "a.c", false},
+		{"ignore case", searchOptions{query: "HELLO"}, "say hello", "hello", false},
+		{"match case", searchOptions{query: "HELLO", matchCase: true}, "say hello", "", false},
+		{"regex", searchOptions{query: `h\w+o`, regex: true}, "say hello", "hello", false},
+		{"plain unbalanced", searchOptions{query: "(a"}, "(a)", "(a", false},
+		{"invalid regex", searchOptions{query: "(a", regex: true}, "", "", true},
+		{"invalid regex ignoring case", searchOptions{query: "a{2,1}", regex: true}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		s, err := tt.opts.compile()
+
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: compile() did not fail", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: compile() error: %s", tt.name, err)
+			continue
+		}
+
+		if got := s.re.FindString(tt.text); got != tt.want {
+			t.Errorf("%s: matched %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       searchOptions
+		text       string
+		from       int
+		start, end int
+		ok         bool
+	}{
+		{"down", searchOptions{query: "cat"}, "cat dog cat", 1, 8, 11, true},
+		{"down from match", searchOptions{query: "cat"}, "cat dog cat", 0, 0, 3, true},
+		{"up", searchOptions{query: "cat", up: true}, "cat dog cat", 8, 0, 3, true},
+		{"down without wrap", searchOptions{query: "cat"}, "cat dog cat", 9, 0, 0, false},
+		{"down wraps", searchOptions{query: "cat", wrap: true}, "cat dog cat", 9, 0, 3, true},
+		{"up without wrap", searchOptions{query: "dog", up: true}, "cat dog cat", 4, 0, 0, false},
+		{"up wraps", searchOptions{query: "cat", up: true, wrap: true}, "dog cat", 2, 4, 7, true},
+		{"not found", searchOptions{query: "bird", wrap: true}, "cat dog", 0, 0, 0, false},
+		{"multibyte", searchOptions{query: "ñu"}, "日本 ñu", 0, 7, 10, true},
+		{"multibyte ignore case", searchOptions{query: "ÉTÉ"}, "un été", 0, 3, 8, true},
+		{"multibyte up", searchOptions{query: "語", up: true}, "語 日本語", 13, 10, 13, true},
+		{"whole word", searchOptions{query: "cat", wholeWord: true}, "concat cat", 0, 7, 10, true},
+		{"whole word at start", searchOptions{query: "cat", wholeWord: true}, "cat concat", 0, 0, 3, true},
+		{"whole word at end", searchOptions{query: "cat", wholeWord: true, up: true}, "concat cat", 10, 7, 10, true},
+		{"whole word inside", searchOptions{query: "cat", wholeWord: true, wrap: true}, "concat cats", 0, 0, 0, false},
+		{"empty matches skipped", searchOptions{query: "x*", regex: true}, "ab xx", 0, 3, 5, true},
+	}
+
+	for _, tt := range tests {
+		s, err := tt.opts.compile()
+		if err != nil {
+			t.Fatalf("%s: compile() error: %s", tt.name, err)
+		}
+
+		start, end, ok := findMatch(s, tt.text, tt.from, tt.opts.up, tt.opts.wrap)
+		if start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("%s: findMatch() = %d, %d, %t, want %d, %d, %t", tt.name, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		opts searchOptions
+		text string
+		want [][2]int
+	}{
+		{"plain", searchOptions{query: "ab"}, "ab cab", [][2]int{{0, 2}, {4, 6}}},
+		{"start of line", searchOptions{query: "^", regex: true}, "one\ntwo", nil},
+		{"word boundary", searchOptions{query: `\b`, regex: true}, "one two", nil},
+		{"star", searchOptions{query: "x*", regex: true}, "ab xx c", [][2]int{{3, 5}}},
+		{"whole word", searchOptions{query: "cat", wholeWord: true}, "cat concat cat", [][2]int{{0, 3}, {11, 14}}},
+	}
+
+	for _, tt := range tests {
+		s, err := tt.opts.compile()
+		if err != nil {
+			t.Fatalf("%s: compile() error: %s", tt.name, err)
+		}
+
+		var got [][2]int
+		for _, m := range s.matches(tt.text) {
+			got = append(got, [2]int{m[0], m[1]})
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsWholeWord(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		want       bool
+	}{
+		{"cat", 0, 3, true},
+		{"a cat", 2, 5, true},
+		{"cat.", 0, 3, true},
+		{"cats", 0, 3, false},
+		{"concat", 3, 6, false},
+		{"snake_cat", 6, 9, false},
+		{"été cat", 6, 9, true},
+		{"étécat", 5, 8, false},
+		{"cat", 1, 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := isWholeWord(tt.text, tt.start, tt.end); got != tt.want {
+			t.Errorf("isWholeWord(%q, %d, %d) = %t, want %t", tt.text, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestCharToByteOffset(t *testing.T) {
+	tests := []struct {
+		text   string
+		offset int
+		want   int
+	}{
+		{"hello", 0, 0},
+		{"hello", 3, 3},
+		{"hello", 5, 5},
+		{"hello", 9, 5},
+		{"日本語", 1, 3},
+		{"日本語", 3, 9},
+		{"aé日b", 3, 6},
+		{"", 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := charToByteOffset(tt.text, tt.offset); got != tt.want {
+			t.Errorf("charToByteOffset(%q, %d) = %d, want %d", tt.text, tt.offset, got, tt.want)
+		}
+
+		if tt.offset <= len([]rune(tt.text)) {
+			if got := byteToCharOffset(tt.text, tt.want); got != tt.offset {
+				t.Errorf("byteToCharOffset(%q, %d) = %d, want %d", tt.text, tt.want, got, tt.offset)
+			}
+		}
+	}
+}
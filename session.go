@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const sessionState = "session.json"
+
+type (
+	// windowGeometry is the size and position of the window when it is not
+	// maximized.
+	windowGeometry struct {
+		X, Y          int
+		Width, Height int
+		Maximized     bool
+	}
+
+	sessionDocument struct {
+		Filename string
+		Encoding string
+		Position viewPosition
+	}
+
+	// session is what was open when the editor last exited.
+	session struct {
+		Window    windowGeometry
+		Current   int
+		Documents []sessionDocument
+	}
+)
+
+func (a *app) loadSession() *session {
+	if !a.config.Session.Enable {
+		return nil
+	}
+
+	s := &session{}
+	if err := loadState(sessionState, s); err != nil {
+		fmt.Printf("failed loading session: %s\n", err)
+		return nil
+	}
+
+	return s
+}
+
+// trackWindowGeometry remembers the window size and position while it is
+// not maximized, so they are not lost when it exits maximized.
+func (a *app) trackWindowGeometry() {
+	a.Win.Connect("configure-event", func(win *gtk.ApplicationWindow, ev *gdk.Event) bool {
+		if !win.IsMaximized() {
+			a.geometry.X, a.geometry.Y = win.GetPosition()
+			a.geometry.Width, a.geometry.Height = win.GetSize()
+		}
+
+		return false
+	})
+}
+
+// RestoreWindowGeometry resizes the window as it was in the last session,
+// before it is shown.
+func (a *app) RestoreWindowGeometry() {
+	s := a.loadSession()
+	if s == nil || s.Window.Width <= 0 || s.Window.Height <= 0 {
+		return
+	}
+
+	a.Win.SetPosition(gtk.WIN_POS_NONE)
+	a.Win.SetDefaultSize(s.Window.Width, s.Window.Height)
+	a.Win.Move(s.Window.X, s.Window.Y)
+
+	if s.Window.Maximized {
+		a.Win.Maximize()
+	}
+}
+
+// RestoreSession reopens the files of the last session where they were left.
+// Files which no longer exist are skipped.
+func (a *app) RestoreSession() {
+	s := a.loadSession()
+	if s == nil {
+		return
+	}
+
+	var current *document
+
+	for i, sd := range s.Documents {
+		if !fileExist(sd.Filename) {
+			continue
+		}
+
+		d := a.OpenFile(sd.Filename, encodingByName(sd.Encoding))
+		d.textView.SetPosition(sd.Position)
+
+		if i == s.Current || current == nil {
+			current = d
+		}
+	}
+
+	if current != nil {
+		a.SetCurrentDocument(current)
+	}
+}
+
+// SaveSession remembers the opened files and the window geometry, done when
+// the window is about to close.
+func (a *app) SaveSession() {
+	if !a.config.Session.Enable || a.application.opts.wait {
+		return
+	}
+
+	s := session{Window: a.geometry}
+	s.Window.Maximized = a.Win.IsMaximized()
+
+	for _, d := range a.documentsInTabOrder() {
+		if !d.isFileOpened {
+			continue
+		}
+
+		if d == a.doc {
+			s.Current = len(s.Documents)
+		}
+
+		s.Documents = append(s.Documents, sessionDocument{
+			Filename: absPath(d.openedFilename),
+			Encoding: d.encoding.Name,
+			Position: d.textView.Position(),
+		})
+	}
+
+	if err := saveState(sessionState, s); err != nil {
+		fmt.Printf("failed saving session: %s\n", err)
+	}
+}
+
+// documentsInTabOrder returns the documents in the order their tabs are
+// shown, which changes when tabs are dragged around.
+func (a *app) documentsInTabOrder() []*document {
+	docs := append([]*document(nil), a.documents...)
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return a.notebook.PageNum(docs[i].textView.page) < a.notebook.PageNum(docs[j].textView.page)
+	})
+
+	return docs
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// stateDir is where the editor remembers things between runs, such as the
+// recent files list. It sits next to the config file in ~/go-notepad.
+func stateDir() string {
+	return filepath.Join(getHomeDir(), "go-notepad")
+}
+
+// loadState reads the JSON state file name into v. A missing file leaves v
+// untouched.
+func loadState(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(stateDir(), name))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// saveState writes v to the JSON state file name.
+func saveState(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(stateDir(), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(stateDir(), name), data)
+}
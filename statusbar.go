@@ -10,6 +10,7 @@ type (
 	statusbar struct {
 		app          *app
 		gtkStatusBar *gtk.Statusbar
+		visible      bool
 	}
 )
 
@@ -32,8 +33,24 @@ func newStatusbar(app *app) *statusbar {
 	}
 }
 
+// SetText shows the cursor position, replacing the last one and any message.
 func (s *statusbar) SetText(text string) {
-	s.gtkStatusBar.Push(s.gtkStatusBar.GetContextId("textView cursor position"), text)
+	s.gtkStatusBar.RemoveAll(s.gtkStatusBar.GetContextId("message"))
+	s.set("textView cursor position", text)
+}
+
+// SetMessage shows a one-off message, such as the result of Replace All, until
+// the cursor position is next updated.
+func (s *statusbar) SetMessage(text string) {
+	s.set("message", text)
+}
+
+// set replaces the text of the context instead of stacking them up.
+func (s *statusbar) set(context, text string) {
+	id := s.gtkStatusBar.GetContextId(context)
+
+	s.gtkStatusBar.RemoveAll(id)
+	s.gtkStatusBar.Push(id, text)
 }
 
 func (s *statusbar) Show() {
@@ -43,6 +60,7 @@ func (s *statusbar) Show() {
 
 	s.app.grid.Add(s.gtkStatusBar)
 	s.app.grid.ShowAll()
+	s.visible = true
 }
 
 func (s *statusbar) Hide() {
@@ -52,4 +70,5 @@ func (s *statusbar) Hide() {
 
 	s.app.grid.Remove(s.gtkStatusBar)
 	s.app.grid.ShowAll()
+	s.visible = false
 }
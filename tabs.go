@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+func (a *app) setupNotebook() {
+	var err error
+
+	a.notebook, err = gtk.NotebookNew()
+	if err != nil {
+		log.Fatal("unable to create notebook:", err)
+	}
+
+	a.notebook.SetScrollable(true)
+	a.notebook.SetShowBorder(false)
+	a.notebook.SetHExpand(true)
+	a.notebook.SetVExpand(true)
+	a.grid.Add(a.notebook)
+
+	a.notebook.Connect("switch-page", func(_ *gtk.Notebook, page *gtk.Widget, _ uint) {
+		if d := a.documentForPage(page); d != nil {
+			a.setCurrentDocument(d)
+		}
+	})
+
+	// Ctrl+Tab is taken by the text view for moving focus, so it is caught
+	// before the focused widget sees it.
+	a.Win.Connect("key-press-event", func(_ *gtk.ApplicationWindow, ev *gdk.Event) bool {
+		key := gdk.EventKeyNewFromEvent(ev)
+
+		if key.State()&uint(gdk.CONTROL_MASK) == 0 {
+			return false
+		}
+
+		switch key.KeyVal() {
+		case gdk.KEY_Tab:
+			a.cycleDocuments(1)
+		case gdk.KEY_ISO_Left_Tab:
+			a.cycleDocuments(-1)
+		default:
+			return false
+		}
+
+		return true
+	})
+}
+
+func (a *app) documentForPage(page *gtk.Widget) *document {
+	for _, d := range a.documents {
+		if d.textView.page.Native() == page.Native() {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// findDocument returns the document which has filename opened.
+func (a *app) findDocument(filename string) *document {
+	filename = absPath(filename)
+
+	for _, d := range a.documents {
+		if d.isFileOpened && d.watchedFilename == filename {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// NewDocument opens an empty Untitled tab and makes it current.
+func (a *app) NewDocument() *document {
+	d := newDocument(a)
+	a.documents = append(a.documents, d)
+
+	tab, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	closeButton, _ := gtk.ButtonNewFromIconName("window-close-symbolic", gtk.ICON_SIZE_MENU)
+	closeButton.SetRelief(gtk.RELIEF_NONE)
+	closeButton.SetFocusOnClick(false)
+	closeButton.SetTooltipText("Close Tab")
+	closeButton.Connect("clicked", func() {
+		a.CloseDocument(d)
+	})
+
+	tab.PackStart(d.tabLabel, true, true, 0)
+	tab.PackStart(closeButton, false, false, 0)
+	tab.ShowAll()
+
+	page := d.textView.page
+	page.ShowAll()
+
+	a.notebook.AppendPage(page, tab)
+	a.notebook.SetTabReorderable(page, true)
+	d.UpdateTitle()
+	a.SetCurrentDocument(d)
+
+	return d
+}
+
+// blankDocument returns the current tab when it is an untouched Untitled
+// document, or a new tab otherwise.
+func (a *app) blankDocument() *document {
+	if a.doc != nil && a.doc.isBlank() {
+		return a.doc
+	}
+
+	return a.NewDocument()
+}
+
+// OpenFile shows filename in a tab, switching to it when it is already open.
+// enc forces an encoding like LoadFile does.
+func (a *app) OpenFile(filename string, enc *textEncoding) *document {
+	if d := a.findDocument(filename); d != nil {
+		a.SetCurrentDocument(d)
+
+		if enc != nil && enc.Name != d.encoding.Name && !d.hasChanges {
+			d.LoadFile(filename, enc)
+		}
+
+		return d
+	}
+
+	d := a.blankDocument()
+	d.LoadFile(filename, enc)
+
+	return d
+}
+
+// OpenURIs opens every file:// URI in uris in its own tab, such as the files
+// dropped on the window.
+func (a *app) OpenURIs(uris []string) {
+	var failed []string
+
+	for _, uri := range uris {
+		filename, err := filenameFromURI(uri)
+
+		if err == nil && !fileExist(filename) {
+			err = fmt.Errorf("%s does not exist", filename)
+		}
+
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+
+		a.OpenFile(filename, nil)
+	}
+
+	if len(failed) > 0 {
+		a.UnexpectedErrorMessageBox("Unable to open the dropped files:\n\n%s", strings.Join(failed, "\n"))
+	}
+}
+
+// CloseDocument closes the tab of d after offering to save it and reports
+// whether it was closed. Closing the last tab leaves an empty one behind.
+func (a *app) CloseDocument(d *document) bool {
+	if !d.confirmClose() {
+		return false
+	}
+
+	a.fileMetadata.Record(d)
+	a.fileMetadata.Save()
+	d.Watch("")
+
+	for i, doc := range a.documents {
+		if doc == d {
+			a.documents = append(a.documents[:i], a.documents[i+1:]...)
+			break
+		}
+	}
+
+	a.notebook.RemovePage(a.notebook.PageNum(d.textView.page))
+	d.closed()
+
+	if len(a.documents) == 0 {
+		a.NewDocument()
+	}
+
+	return true
+}
+
+// CloseAll offers to save every document with unsaved changes and reports
+// whether the window may close.
+func (a *app) CloseAll() bool {
+	for _, d := range a.documents {
+		if !d.confirmClose() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetCurrentDocument switches to the tab of d.
+func (a *app) SetCurrentDocument(d *document) {
+	a.notebook.SetCurrentPage(a.notebook.PageNum(d.textView.page))
+}
+
+func (a *app) cycleDocuments(step int) {
+	n := a.notebook.GetNPages()
+	a.notebook.SetCurrentPage((a.notebook.GetCurrentPage() + step + n) % n)
+}
+
+// setCurrentDocument brings the window and menus in line with d after its
+// tab was selected.
+func (a *app) setCurrentDocument(d *document) {
+	a.doc = d
+
+	d.SetLineEnding(d.lineEnding)
+	d.SetWrap(d.wrap)
+	d.SetLanguage(d.language)
+	d.textView.history.updateMenu()
+	a.updateSelectionMenu()
+	a.updateStatusBar()
+	a.UpdateTitle()
+
+	d.textView.GTKtextView.GrabFocus()
+}
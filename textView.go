@@ -6,18 +6,52 @@ import (
 	"os"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
+const (
+	dropURIList uint = iota
+	dropText
+)
+
+// dropTargets are the kinds of data which can be dropped on the text view,
+// files are preferred over their names as text.
+var dropTargets = []struct {
+	name string
+	info uint
+}{
+	{"text/uri-list", dropURIList},
+	{"UTF8_STRING", dropText},
+	{"text/plain;charset=utf-8", dropText},
+	{"text/plain", dropText},
+}
+
+// viewPosition is where the cursor, the selection and the scrolled view of a
+// text view are, as character offsets.
+type viewPosition struct {
+	Cursor int
+	Bound  int // other end of the selection, the cursor when nothing is selected
+	Top    int // first visible character
+}
+
 type textView struct {
-	app         *app
+	doc         *document
 	GTKtextView *gtk.TextView
+	scrolled    *gtk.ScrolledWindow
+	gutter      *gutter
+	highlighter *highlighter
+	history     *history
+
+	// page holds the gutter and the scrolled text view, it is the tab of
+	// the document.
+	page *gtk.Box
 }
 
-func newTextView(app *app) *textView {
+func newTextView(doc *document) *textView {
 	tv, err := gtk.TextViewNew()
 
 	if err != nil {
@@ -38,33 +72,64 @@ func newTextView(app *app) *textView {
 	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
 
 	scrolled.Add(tv)
-	app.grid.Add(scrolled)
 
 	tv.SetMonospace(true)
 
-	target, err := gtk.TargetEntryNew("text/uri-list", gtk.TargetFlags(0), 0)
-	if err != nil {
-		log.Fatal("failed creating target for textView", err)
+	var targets []gtk.TargetEntry
+
+	for _, t := range dropTargets {
+		target, err := gtk.TargetEntryNew(t.name, gtk.TargetFlags(0), t.info)
+		if err != nil {
+			log.Fatal("failed creating target for textView", err)
+		}
+
+		targets = append(targets, *target)
 	}
 
-	tv.DragDestSet(gtk.DEST_DEFAULT_ALL, []gtk.TargetEntry{*target}, gdk.ACTION_COPY)
+	tv.DragDestSet(gtk.DEST_DEFAULT_ALL, targets, gdk.ACTION_COPY|gdk.ACTION_MOVE)
 
-	return &textView{
-		app:         app,
+	page, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+
+	if err != nil {
+		log.Fatal("failed setting up gtk page: ", err)
+	}
+
+	t := &textView{
+		doc:         doc,
 		GTKtextView: tv,
+		scrolled:    scrolled,
+		history:     newHistory(doc, tv),
+		page:        page,
 	}
+
+	t.gutter = newGutter(t)
+	t.highlighter = newHighlighter(t)
+
+	page.PackStart(t.gutter.area, false, false, 0)
+	page.PackStart(scrolled, true, true, 0)
+
+	return t
 }
 
-func (t *textView) SetFont(font string, size int64) error {
+// fontProvider styles every text view with the font, zooming replaces its
+// style sheet rather than adding another one each time.
+var fontProvider *gtk.CssProvider
+
+// SetFont sets the font of the text, size is in points and may be a fraction
+// when zoomed.
+func (t *textView) SetFont(font string, size float64) error {
 	styleContext, err := t.GTKtextView.GetStyleContext()
 
 	if err != nil {
 		return err
 	}
 
-	cssProvider, err := gtk.CssProviderNew()
-	if err != nil {
-		return err
+	cssProvider := fontProvider
+	if cssProvider == nil {
+		cssProvider, err = gtk.CssProviderNew()
+		if err != nil {
+			return err
+		}
 	}
 
 	css := `
@@ -72,7 +137,7 @@ func (t *textView) SetFont(font string, size int64) error {
 		padding-top: 2px;
 		padding-left: 2px;
 		font-family: "` + font + `", "Lucida Console";
-		font-size: ` + strconv.FormatInt(size, 10) + `pt;
+		font-size: ` + strconv.FormatFloat(size, 'f', -1, 64) + `pt;
 	}
 	`
 
@@ -89,10 +154,15 @@ func (t *textView) SetFont(font string, size int64) error {
 	}
 
 	// Add the CSS provider to the screen's style context.
-	gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	if fontProvider == nil {
+		gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+		fontProvider = cssProvider
+	}
+
 	t.GTKtextView.ShowAll()
+	t.gutter.SetFont(font, size)
 
-	fmt.Printf("setting font: '%s' %d\n", font, size)
+	fmt.Printf("setting font: '%s' %g\n", font, size)
 
 	return nil
 }
@@ -154,38 +224,89 @@ func (t *textView) Backspace() {
 	t.GTKtextView.Emit("backspace", glib.TYPE_NONE)
 }
 
-func (t *textView) LoadSource(filename string) (err error) {
-	src, err := os.ReadFile(filename)
+// LoadSource reads filename into the buffer, decoding it with enc or with the
+// detected encoding when enc is nil. It returns the encoding used, the line
+// ending of the file and whether the file mixes line endings. The buffer is
+// left as it was when the file can not be loaded.
+func (t *textView) LoadSource(filename string, enc *textEncoding) (used *textEncoding, ending lineEnding, mixed bool, err error) {
+	text, used, ending, mixed, err := readSource(filename, enc)
 
 	if err != nil {
 		return
 	}
 
-	buff, err := t.GTKtextView.GetBuffer()
+	t.loadText(text)
+
+	return used, ending, mixed, nil
+}
+
+// LoadData is LoadSource for data which does not come from a file, such as
+// stdin.
+func (t *textView) LoadData(src []byte, enc *textEncoding) (used *textEncoding, ending lineEnding, mixed bool, err error) {
+	text, used, ending, mixed, err := decodeSource(src, enc)
 
 	if err != nil {
 		return
 	}
 
+	t.loadText(text)
+
+	return used, ending, mixed, nil
+}
+
+func (t *textView) loadText(text string) {
+	buff, _ := t.GTKtextView.GetBuffer()
+
 	t.Clear()
-	buff.Insert(buff.GetStartIter(), string(src))
+	buff.Insert(buff.GetStartIter(), text)
+}
 
-	return
+// readSource reads filename and decodes it like decodeSource.
+func readSource(filename string, enc *textEncoding) (text string, used *textEncoding, ending lineEnding, mixed bool, err error) {
+	src, err := os.ReadFile(filename)
+
+	if err != nil {
+		return
+	}
+
+	return decodeSource(src, enc)
 }
 
-func (t *textView) SaveSource(filename string) error {
+// decodeSource decodes src with enc, or with the detected encoding when enc is
+// nil, into the "\n" separated text of the buffer.
+func decodeSource(src []byte, enc *textEncoding) (text string, used *textEncoding, ending lineEnding, mixed bool, err error) {
+	if enc == nil {
+		enc = detectEncoding(src)
+	}
+
+	enc = enc.forData(src)
+	text, err = enc.Decode(src)
+
+	if err != nil {
+		return
+	}
+
+	ending, mixed = detectLineEnding(text)
+
+	return normalizeLineEndings(text), enc, ending, mixed, nil
+}
+
+func (t *textView) SaveSource(filename string, enc *textEncoding, ending lineEnding) error {
 	buff, _ := t.GTKtextView.GetBuffer()
 
-	// TODO: Add a write file error
 	source, err := buff.GetText(buff.GetStartIter(), buff.GetEndIter(), true)
 
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(filename, []byte(source), 0666)
+	data, err := enc.Encode(ending.apply(source))
+
+	if err != nil {
+		return err
+	}
 
-	return err
+	return writeFileAtomic(filename, data)
 }
 
 func (t *textView) Clear() {
@@ -198,10 +319,275 @@ func (t *textView) InsertTimestamp() {
 	timestamp := time.Now().Format("1:04 PM 02/01/2006")
 
 	buff, _ := t.GTKtextView.GetBuffer()
-	buff.InsertAtCursor(timestamp)
+	buff.InsertInteractiveAtCursor(timestamp, t.GTKtextView.GetEditable())
 }
 
 func (t *textView) GoToLine(i int) {
 	buff, _ := t.GTKtextView.GetBuffer()
 	buff.PlaceCursor(buff.GetIterAtLine(i))
 }
+
+// Text returns the whole contents of the buffer.
+func (t *textView) Text() string {
+	buff, _ := t.GTKtextView.GetBuffer()
+	text, _ := buff.GetText(buff.GetStartIter(), buff.GetEndIter(), true)
+
+	return text
+}
+
+// SelectedText returns the currently selected text or an empty string.
+func (t *textView) SelectedText() string {
+	buff, _ := t.GTKtextView.GetBuffer()
+	start, end, ok := buff.GetSelectionBounds()
+
+	if !ok {
+		return ""
+	}
+
+	text, _ := buff.GetText(start, end, true)
+
+	return text
+}
+
+// CursorOffset returns the character offset of the cursor.
+func (t *textView) CursorOffset() int {
+	buff, _ := t.GTKtextView.GetBuffer()
+	return buff.GetIterAtMark(buff.GetInsert()).GetOffset()
+}
+
+// Position returns the cursor, selection and scroll position to restore them
+// later with SetPosition.
+func (t *textView) Position() viewPosition {
+	buff, _ := t.GTKtextView.GetBuffer()
+	rect := t.GTKtextView.GetVisibleRect()
+
+	return viewPosition{
+		Cursor: buff.GetIterAtMark(buff.GetInsert()).GetOffset(),
+		Bound:  buff.GetIterAtMark(buff.GetSelectionBound()).GetOffset(),
+		Top:    t.GTKtextView.GetIterAtLocation(rect.GetX(), rect.GetY()).GetOffset(),
+	}
+}
+
+// SetPosition restores a position returned by Position. Scrolling waits for
+// the text to be laid out, so it works right after loading a file.
+func (t *textView) SetPosition(p viewPosition) {
+	buff, _ := t.GTKtextView.GetBuffer()
+	buff.SelectRange(buff.GetIterAtOffset(p.Cursor), buff.GetIterAtOffset(p.Bound))
+
+	t.scrollToTop(buff.GetIterAtOffset(p.Top))
+}
+
+// scrollToTop scrolls iter to the top of the view. Scrolling waits for the
+// text to be laid out, so it works right after loading a file.
+func (t *textView) scrollToTop(iter *gtk.TextIter) {
+	buff, _ := t.GTKtextView.GetBuffer()
+
+	top := buff.CreateMark("restore-top", iter, true)
+	t.GTKtextView.ScrollToMark(top, 0, true, 0, 0)
+	buff.DeleteMark(top)
+}
+
+// CursorLineColumn returns the zero based line and column of the cursor.
+func (t *textView) CursorLineColumn() (line, col int) {
+	buff, _ := t.GTKtextView.GetBuffer()
+	iter := buff.GetIterAtMark(buff.GetInsert())
+
+	return iter.GetLine(), iter.GetLineOffset()
+}
+
+// TopLine returns the zero based first visible line.
+func (t *textView) TopLine() int {
+	rect := t.GTKtextView.GetVisibleRect()
+	return t.GTKtextView.GetIterAtLocation(rect.GetX(), rect.GetY()).GetLine()
+}
+
+// iterAtLineColumn returns the position of the zero based line and column,
+// clamped to the text.
+func (t *textView) iterAtLineColumn(line, col int) *gtk.TextIter {
+	buff, _ := t.GTKtextView.GetBuffer()
+
+	if line < 0 {
+		line = 0
+	}
+
+	end := buff.GetIterAtLine(line)
+	if !end.EndsLine() {
+		end.ForwardToLineEnd()
+	}
+
+	if col > end.GetLineOffset() {
+		col = end.GetLineOffset()
+	}
+
+	if col < 0 {
+		col = 0
+	}
+
+	iter := buff.GetIterAtLine(line)
+	iter.SetLineOffset(col)
+
+	return iter
+}
+
+// GoToLineColumn moves the cursor to the zero based line and column and
+// scrolls it into view.
+func (t *textView) GoToLineColumn(line, col int) {
+	buff, _ := t.GTKtextView.GetBuffer()
+	buff.PlaceCursor(t.iterAtLineColumn(line, col))
+	t.GTKtextView.ScrollToMark(buff.GetInsert(), 0.1, false, 0, 0)
+}
+
+// SelectLines selects the zero based lines from and to and everything between
+// them, leaving the cursor on the side of to.
+func (t *textView) SelectLines(from, to int) {
+	buff, _ := t.GTKtextView.GetBuffer()
+
+	start, end := from, to
+	if start > end {
+		start, end = end, start
+	}
+
+	first := buff.GetIterAtLine(start)
+	last := buff.GetIterAtLine(end)
+	if !last.ForwardLine() {
+		last = buff.GetEndIter()
+	}
+
+	if from <= to {
+		buff.SelectRange(last, first)
+	} else {
+		buff.SelectRange(first, last)
+	}
+
+	t.GTKtextView.ScrollToMark(buff.GetInsert(), 0, false, 0, 0)
+}
+
+// ScrollToLine scrolls the zero based line to the top of the view.
+func (t *textView) ScrollToLine(line int) {
+	t.scrollToTop(t.iterAtLineColumn(line, 0))
+}
+
+// InsertAtCursor inserts text where the cursor is, replacing the selection,
+// and reports whether it was inserted.
+func (t *textView) InsertAtCursor(text string) bool {
+	buff, _ := t.GTKtextView.GetBuffer()
+	editable := t.GTKtextView.GetEditable()
+
+	buff.BeginUserAction()
+	defer buff.EndUserAction()
+
+	if buff.GetHasSelection() && !buff.DeleteSelection(true, editable) {
+		return false
+	}
+
+	return buff.InsertInteractiveAtCursor(normalizeLineEndings(text), editable)
+}
+
+// InsertAtLocation inserts text at the window coordinates x, y, such as
+// where it was dropped, and selects it.
+func (t *textView) InsertAtLocation(x, y int, text string) {
+	bx, by := t.GTKtextView.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, x, y)
+	iter := t.GTKtextView.GetIterAtLocation(bx, by)
+	offset := iter.GetOffset()
+	text = normalizeLineEndings(text)
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	buff.BeginUserAction()
+	inserted := buff.InsertInteractive(iter, text, t.GTKtextView.GetEditable())
+	buff.EndUserAction()
+
+	if inserted {
+		t.SelectRange(offset, offset+utf8.RuneCountInString(text))
+	}
+}
+
+// SelectRange selects the characters between the start and end offsets and
+// scrolls the selection into view.
+func (t *textView) SelectRange(start, end int) {
+	buff, _ := t.GTKtextView.GetBuffer()
+	buff.SelectRange(buff.GetIterAtOffset(start), buff.GetIterAtOffset(end))
+	t.GTKtextView.ScrollToMark(buff.GetInsert(), 0.1, false, 0, 0)
+}
+
+// Find selects the next match of opts after (or before when searching up) the
+// current selection and reports whether anything was found.
+func (t *textView) Find(opts searchOptions) (bool, error) {
+	s, err := opts.compile()
+
+	if err != nil {
+		return false, err
+	}
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	text := t.Text()
+	selStart, selEnd, _ := buff.GetSelectionBounds()
+
+	from := selEnd.GetOffset()
+	if opts.up {
+		from = selStart.GetOffset()
+	}
+
+	start, end, ok := findMatch(s, text, charToByteOffset(text, from), opts.up, opts.wrap)
+
+	if !ok {
+		return false, nil
+	}
+
+	t.SelectRange(byteToCharOffset(text, start), byteToCharOffset(text, end))
+
+	return true, nil
+}
+
+// Replace swaps the selection for replacement when it is a match of opts and
+// then selects the following match.
+func (t *textView) Replace(opts searchOptions, replacement string) (bool, error) {
+	s, err := opts.compile()
+
+	if err != nil {
+		return false, err
+	}
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	text := t.Text()
+	selStart, selEnd, _ := buff.GetSelectionBounds()
+	start := charToByteOffset(text, selStart.GetOffset())
+	end := charToByteOffset(text, selEnd.GetOffset())
+
+	if m := matchAt(s, text, start, end); m != nil {
+		buff.BeginUserAction()
+		buff.Delete(selStart, selEnd)
+		buff.Insert(selStart, s.replacement(text, m, replacement))
+		buff.EndUserAction()
+	}
+
+	return t.Find(opts)
+}
+
+// ReplaceAll replaces every match of opts as a single user action so it can
+// be undone in one step, returning the number of replacements.
+func (t *textView) ReplaceAll(opts searchOptions, replacement string) (int, error) {
+	s, err := opts.compile()
+
+	if err != nil {
+		return 0, err
+	}
+
+	buff, _ := t.GTKtextView.GetBuffer()
+	text := t.Text()
+	matches := s.matches(text)
+	offsets := matchCharOffsets(text, matches)
+
+	buff.BeginUserAction()
+
+	// Work backwards so earlier offsets stay valid while the buffer changes.
+	for i := len(matches) - 1; i >= 0; i-- {
+		start := buff.GetIterAtOffset(offsets[i][0])
+		end := buff.GetIterAtOffset(offsets[i][1])
+		buff.Delete(start, end)
+		buff.Insert(start, s.replacement(text, matches[i], replacement))
+	}
+
+	buff.EndUserAction()
+
+	return len(matches), nil
+}
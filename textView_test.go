@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// failingEncoding is an encoding which can not decode anything, like a file
+// opened with the wrong --encoding would be if decoders were strict.
+type failingEncoding struct{}
+
+type failingTransformer struct {
+	transform.NopResetter
+}
+
+func (failingEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: failingTransformer{}}
+}
+
+func (failingEncoding) NewEncoder() *encoding.Encoder {
+	return &encoding.Encoder{Transformer: failingTransformer{}}
+}
+
+func (failingTransformer) Transform(dst, src []byte, atEOF bool) (int, int, error) {
+	return 0, 0, errors.New("invalid byte")
+}
+
+func TestReadSource(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "notes.txt")
+
+	if err := os.WriteFile(filename, []byte("one\r\ntwo\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := &textEncoding{Name: "broken", encoding: failingEncoding{}}
+
+	tests := []struct {
+		name     string
+		filename string
+		enc      *textEncoding
+		text     string
+		used     *textEncoding
+		ending   lineEnding
+		err      bool
+	}{
+		{"detected", filename, nil, "one\ntwo\n", encodingUTF8, lineEndingCRLF, false},
+		{"forced", filename, encodingCP1252, "one\ntwo\n", encodingCP1252, lineEndingCRLF, false},
+		{"bad encoding", filename, broken, "", nil, 0, true},
+		{"missing file", filepath.Join(dir, "missing.txt"), nil, "", nil, 0, true},
+		{"directory", dir, nil, "", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		text, used, ending, _, err := readSource(tt.filename, tt.enc)
+
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: readSource() did not fail", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: readSource() error: %s", tt.name, err)
+			continue
+		}
+
+		if text != tt.text || used != tt.used || ending != tt.ending {
+			t.Errorf("%s: readSource() = %q, %s, %s, want %q, %s, %s", tt.name, text, used.Name, ending, tt.text, tt.used.Name, tt.ending)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const maxUndoSteps = 1000
+
+type (
+	// undoAction is a single insertion or deletion of text at a character
+	// offset of the buffer.
+	undoAction struct {
+		insert bool
+		offset int
+		text   string
+	}
+
+	// undoStep is a group of actions which are undone and redone together.
+	undoStep []undoAction
+
+	// history records the edits made to a text buffer so they can be undone
+	// and redone.
+	history struct {
+		doc    *document
+		view   *gtk.TextView
+		buffer *gtk.TextBuffer
+
+		undoStack []undoStep
+		redoStack []undoStep
+
+		// savePoint is the undo stack depth matching the file on disk or -1
+		// when that state can no longer be reached.
+		savePoint int
+
+		pending      undoStep
+		inUserAction bool
+		applying     bool
+		canMerge     bool
+	}
+)
+
+func newHistory(doc *document, view *gtk.TextView) *history {
+	buffer, _ := view.GetBuffer()
+
+	h := &history{
+		doc:    doc,
+		view:   view,
+		buffer: buffer,
+	}
+
+	buffer.Connect("begin-user-action", func() {
+		h.inUserAction = true
+	})
+
+	buffer.Connect("end-user-action", func() {
+		h.inUserAction = false
+		h.commit()
+	})
+
+	buffer.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, text string) {
+		h.record(undoAction{insert: true, offset: iter.GetOffset(), text: text})
+	})
+
+	buffer.Connect("delete-range", func(_ *gtk.TextBuffer, start, end *gtk.TextIter) {
+		text, _ := buffer.GetText(start, end, true)
+		h.record(undoAction{insert: false, offset: start.GetOffset(), text: text})
+	})
+
+	h.Reset()
+
+	return h
+}
+
+// Reset forgets all history, used whenever a new document is loaded.
+func (h *history) Reset() {
+	h.undoStack = nil
+	h.redoStack = nil
+	h.pending = nil
+	h.savePoint = 0
+	h.canMerge = false
+	h.updateMenu()
+}
+
+// MarkSaved remembers the current state as the one written to disk.
+func (h *history) MarkSaved() {
+	h.savePoint = len(h.undoStack)
+	h.canMerge = false
+}
+
+// ForgetSavePoint is used for changes made outside of the buffer, such as
+// converting line endings, which undo can not bring back.
+func (h *history) ForgetSavePoint() {
+	h.savePoint = -1
+}
+
+// IsModified reports whether the buffer differs from the saved state.
+func (h *history) IsModified() bool {
+	return len(h.undoStack) != h.savePoint
+}
+
+func (h *history) CanUndo() bool {
+	return len(h.undoStack) > 0
+}
+
+func (h *history) CanRedo() bool {
+	return len(h.redoStack) > 0
+}
+
+func (h *history) record(action undoAction) {
+	if h.applying || action.text == "" {
+		return
+	}
+
+	h.pending = append(h.pending, action)
+
+	if !h.inUserAction {
+		h.commit()
+	}
+}
+
+// commit pushes the pending actions onto the undo stack, merging consecutive
+// typing or deleting into word sized steps.
+func (h *history) commit() {
+	if len(h.pending) == 0 {
+		return
+	}
+
+	step := h.pending
+	h.pending = nil
+
+	if h.redoStack != nil {
+		h.redoStack = nil
+
+		if h.savePoint > len(h.undoStack) {
+			h.savePoint = -1
+		}
+	}
+
+	if h.canMerge && len(step) == 1 && len(h.undoStack) > 0 {
+		top := h.undoStack[len(h.undoStack)-1]
+
+		if len(top) == 1 {
+			if merged, ok := mergeActions(top[0], step[0]); ok {
+				top[0] = merged
+				h.updateMenu()
+				return
+			}
+		}
+	}
+
+	h.undoStack = append(h.undoStack, step)
+	h.canMerge = len(step) == 1 && isMergeable(step[0].text)
+
+	if len(h.undoStack) > maxUndoSteps {
+		h.undoStack = h.undoStack[1:]
+		h.savePoint--
+	}
+
+	h.updateMenu()
+}
+
+// mergeActions joins next onto prev when both are single characters typed or
+// deleted next to each other, breaking at the start of each new word.
+func mergeActions(prev, next undoAction) (undoAction, bool) {
+	if prev.insert != next.insert || !isMergeable(next.text) {
+		return prev, false
+	}
+
+	switch {
+	case next.insert && next.offset == prev.offset+utf8.RuneCountInString(prev.text):
+		// Typing
+		if isWordBoundary(prev.text, next.text) {
+			return prev, false
+		}
+
+		prev.text += next.text
+	case !next.insert && next.offset+1 == prev.offset:
+		// Backspace
+		if isWordBoundary(next.text, prev.text) {
+			return prev, false
+		}
+
+		prev.text = next.text + prev.text
+		prev.offset = next.offset
+	case !next.insert && next.offset == prev.offset:
+		// Delete
+		if isWordBoundary(prev.text, next.text) {
+			return prev, false
+		}
+
+		prev.text += next.text
+	default:
+		return prev, false
+	}
+
+	return prev, true
+}
+
+// isWordBoundary reports whether whitespace starts between the left and right
+// pieces of text.
+func isWordBoundary(left, right string) bool {
+	l, _ := utf8.DecodeLastRuneInString(left)
+	r, _ := utf8.DecodeRuneInString(right)
+
+	return unicode.IsSpace(r) && !unicode.IsSpace(l)
+}
+
+func isMergeable(text string) bool {
+	return utf8.RuneCountInString(text) == 1 && text != "\n"
+}
+
+func (h *history) Undo() {
+	if !h.CanUndo() {
+		return
+	}
+
+	step := h.undoStack[len(h.undoStack)-1]
+	h.undoStack = h.undoStack[:len(h.undoStack)-1]
+	h.redoStack = append(h.redoStack, step)
+
+	h.apply(func() {
+		for i := len(step) - 1; i >= 0; i-- {
+			h.revert(step[i])
+		}
+	})
+}
+
+func (h *history) Redo() {
+	if !h.CanRedo() {
+		return
+	}
+
+	step := h.redoStack[len(h.redoStack)-1]
+	h.redoStack = h.redoStack[:len(h.redoStack)-1]
+	h.undoStack = append(h.undoStack, step)
+
+	h.apply(func() {
+		for _, action := range step {
+			h.revert(undoAction{insert: !action.insert, offset: action.offset, text: action.text})
+		}
+	})
+}
+
+// revert performs the opposite of action and leaves the cursor where it happened.
+func (h *history) revert(action undoAction) {
+	start := h.buffer.GetIterAtOffset(action.offset)
+
+	if action.insert {
+		end := h.buffer.GetIterAtOffset(action.offset + utf8.RuneCountInString(action.text))
+		h.buffer.Delete(start, end)
+	} else {
+		h.buffer.Insert(start, action.text)
+	}
+
+	h.buffer.PlaceCursor(start)
+}
+
+func (h *history) apply(fn func()) {
+	h.applying = true
+	fn()
+	h.applying = false
+	h.canMerge = false
+
+	h.view.ScrollToMark(h.buffer.GetInsert(), 0.1, false, 0, 0)
+	h.doc.hasChanges = h.IsModified()
+	h.doc.UpdateTitle()
+	h.updateMenu()
+}
+
+// updateMenu enables Undo and Redo for the current document.
+func (h *history) updateMenu() {
+	a := h.doc.app
+
+	if a.actions == nil || a.doc != h.doc {
+		return
+	}
+
+	a.actions["undo"].SetEnabled(h.CanUndo())
+	a.actions["redo"].SetEnabled(h.CanRedo())
+}
@@ -0,0 +1,50 @@
+package main
+
+// #cgo pkg-config: glib-2.0
+// #include <stdlib.h>
+// #include <glib.h>
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// filenameFromURI converts a file:// URI into a local file name, decoding
+// escaped characters such as spaces, with g_filename_from_uri(). gotk3 does
+// not wrap it.
+func filenameFromURI(uri string) (string, error) {
+	cURI := C.CString(uri)
+	defer C.free(unsafe.Pointer(cURI))
+
+	var gErr *C.GError
+	cFilename := C.g_filename_from_uri((*C.gchar)(cURI), nil, &gErr)
+
+	if cFilename == nil {
+		defer C.g_error_free(gErr)
+		return "", errors.New(C.GoString((*C.char)(gErr.message)))
+	}
+
+	defer C.g_free(C.gpointer(cFilename))
+
+	return C.GoString((*C.char)(cFilename)), nil
+}
+
+// filenameToURI converts a local file name into a file:// URI with
+// g_filename_to_uri().
+func filenameToURI(filename string) (string, error) {
+	cFilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cFilename))
+
+	var gErr *C.GError
+	cURI := C.g_filename_to_uri((*C.gchar)(cFilename), nil, &gErr)
+
+	if cURI == nil {
+		defer C.g_error_free(gErr)
+		return "", errors.New(C.GoString((*C.char)(gErr.message)))
+	}
+
+	defer C.g_free(C.gpointer(cURI))
+
+	return C.GoString((*C.char)(cURI)), nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"runtime"
 )
 
@@ -22,3 +23,24 @@ func getHomeDir() string {
 	}
 	return os.Getenv("HOME")
 }
+
+func getCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return dir
+	}
+	return getHomeDir()
+}
+
+// absPath returns the absolute form of filename, or filename itself when it
+// is empty or cannot be resolved.
+func absPath(filename string) string {
+	if filename == "" {
+		return ""
+	}
+
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+
+	return filename
+}